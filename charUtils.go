@@ -1,5 +1,10 @@
 package regexp4
 
+import (
+  "sort"
+  "unsafe"
+)
+
 func isDigit( c rune ) bool { return c >= '0' && c <= '9' }
 func isUpper( c rune ) bool { return c >= 'a' && c <= 'z' }
 func isLower( c rune ) bool { return c >= 'A' && c <= 'Z' }
@@ -7,13 +12,97 @@ func isAlpha( c rune ) bool { return isLower( c ) || isUpper( c ) }
 func isAlnum( c rune ) bool { return isAlpha( c ) || isDigit( c ) }
 func isSpace( c rune ) bool { return c == ' ' || (c >= '\t' && c <= '\r') }
 func isBlank( c rune ) bool { return c == ' ' || c == '\t' }
+func isXDigit( c rune ) bool { return isDigit( c ) || (c | 0x20 >= 'a' && c | 0x20 <= 'f') }
 
-func toLower( c rune ) rune {
-  if isLower( c ) { return c + 32 }
+// runeTable is a sorted list of rune ranges, generated by gen_tables.go
+type runeTable []struct{ lo, hi rune }
 
-  return c
+func (t runeTable) has( c rune ) bool {
+  for lo, hi := 0, len( t ); lo < hi; {
+    m := int( uint( lo + hi ) >> 1 )
+    switch {
+    case c < t[m].lo: hi = m
+    case c > t[m].hi: lo = m + 1
+    default         : return true
+    }
+  }
+
+  return false
+}
+
+const runeMax = 0x10FFFF // last rune of Unicode
+
+func (t runeTable) add( lo, hi rune ) runeTable {
+  return append( t, struct{ lo, hi rune }{ lo, hi } )
+}
+
+// merge sorts t and joins the ranges that overlap or touch
+func (t runeTable) merge() runeTable {
+  if !t.sorted() { sort.Slice( t, func( i, j int ) bool { return t[i].lo < t[j].lo } ) }
+
+  var m runeTable
+  for _, r := range t {
+    if n := len( m ); n > 0 && r.lo <= m[n - 1].hi + 1 {
+      if r.hi > m[n - 1].hi { m[n - 1].hi = r.hi }
+    } else {
+      m = m.add( r.lo, r.hi )
+    }
+  }
+
+  return m
+}
+
+func (t runeTable) sorted() bool {
+  for i := 1; i < len( t ); i++ {
+    if t[i].lo < t[i - 1].lo { return false }
+  }
+
+  return true
+}
+
+// negate returns the runes out of the merged table t
+func (t runeTable) negate() (n runeTable) {
+  lo := rune( 0 )
+  for _, r := range t {
+    if r.lo > lo { n = n.add( lo, r.lo - 1 ) }
+    lo = r.hi + 1
+  }
+
+  if lo <= runeMax { n = n.add( lo, runeMax ) }
+  return
+}
+
+// intersect returns the runes of the merged tables t and u
+func (t runeTable) intersect( u runeTable ) runeTable {
+  return append( t.negate(), u.negate()... ).merge().negate()
+}
+
+// subtract returns the runes of the merged table t that are not in u
+func (t runeTable) subtract( u runeTable ) runeTable {
+  return t.intersect( u.negate() )
 }
 
+// fold adds to t the runes of the case folding orbits of its runes, only the
+// pairs of tableFold inside its ranges are visited
+func (t runeTable) fold() runeTable {
+  f := append( runeTable{}, t... )
+  for _, r := range t {
+    k := sort.Search( len( tableFold ), func( i int ) bool { return tableFold[i].from >= r.lo } )
+    for ; k < len( tableFold ) && tableFold[k].from <= r.hi; k++ {
+      p := tableFold[k]
+      for c := p.to; c != p.from; c = simpleFold( c ) { f = f.add( c, c ) }
+    }
+  }
+
+  return f.merge()
+}
+
+func isLetterU( c rune ) bool { return tableLetter.has( c ) }
+func isDigitU ( c rune ) bool { return tableDigit.has ( c ) }
+func isAlnumU ( c rune ) bool { return isLetterU( c ) || isDigitU( c ) }
+func isSpaceU ( c rune ) bool { return tableSpace.has ( c ) }
+func isBlankU ( c rune ) bool { return tableBlank.has ( c ) }
+
 func strnchr( str string, v rune ) bool {
   for _, c := range( str) {
     if c == v { return true }
@@ -22,21 +111,61 @@ func strnchr( str string, v rune ) bool {
   return false
 }
 
-func findRuneCommunist( str string, chr rune ) bool {
-  chr = toLower( chr )
-  for _, c := range str {
-    if toLower( c ) == chr { return true }
+// foldTable is the simple case folding as pairs of a rune and the next rune of
+// its orbit, sorted by rune, generated by gen_tables.go
+type foldTable []struct{ from, to rune }
+
+// simpleFold returns the next rune of the case folding orbit of c, c itself
+// when it has no other case
+func simpleFold( c rune ) rune {
+  for lo, hi := 0, len( tableFold ); lo < hi; {
+    m := int( uint( lo + hi ) >> 1 )
+    switch {
+    case c < tableFold[m].from: hi = m
+    case c > tableFold[m].from: lo = m + 1
+    default                   : return tableFold[m].to
+    }
+  }
+
+  return c
+}
+
+// equalFold reports if a and b are the same rune under simple case folding
+func equalFold( a, b rune ) bool {
+  if a == b { return true }
+
+  for c := simpleFold( a ); c != a; c = simpleFold( c ) {
+    if c == b { return true }
   }
 
-  return true;
+  return false
 }
 
-func strnEqlCommunist( s, t string, n int ) bool {
-  for i := 0; i < n; i++ {
-    if toLower( rune(s[i]) ) != toLower( rune(t[i]) ) { return false }
+// inRangeFold reports if c or any case of c is between lo and hi
+func inRangeFold( c, lo, hi rune ) bool {
+  if c >= lo && c <= hi { return true }
+
+  for f := simpleFold( c ); f != c; f = simpleFold( f ) {
+    if f >= lo && f <= hi { return true }
   }
 
-  return true;
+  return false
+}
+
+// strEqlCommunist compares the runes of str with the start of txt under simple
+// case folding, returns the bytes of txt that matched, or a length beyond txt
+// when txt ends before str
+func strEqlCommunist( str, txt string ) (int, bool) {
+  n := 0
+  for _, c := range str {
+    if n >= len( txt ) { return len( txt ) + 1, false }
+
+    t, size := decodeRune( txt[n:] )
+    if !equalFold( c, t ) { return n, false }
+    n += size
+  }
+
+  return n, true
 }
 
 func aToi( str string ) ( number int ) {
@@ -49,6 +178,68 @@ func aToi( str string ) ( number int ) {
   return
 }
 
+// hexToi returns the value of the hexadecimal digits of str
+func hexToi( str string ) ( number int ) {
+  for _, c := range str {
+    switch {
+    case isDigit( c ) : number = 16 * number + int( c - '0' )
+    case isXDigit( c ): number = 16 * number + int( c | 0x20 - 'a' ) + 10
+    default           : return
+    }
+  }
+
+  return
+}
+
+func iToa( number int ) string {
+  if number == 0 { return "0" }
+
+  var buf [20]byte
+  i, negative := len( buf ), number < 0
+  if negative { number = -number }
+
+  for ; number > 0; number /= 10 {
+    i--
+    buf[i] = byte( '0' + number % 10 )
+  }
+
+  if negative { i--; buf[i] = '-' }
+
+  return string( buf[i:] )
+}
+
+// nextLine returns the length of the line at the start of str, including the
+// '\n'
+func nextLine( str string ) int {
+  for i := 0; i < len( str ); i++ {
+    if str[i] == '\n' { return i + 1 }
+  }
+
+  return len( str )
+}
+
+// decodeRune returns the first rune of str and its length, an invalid byte
+// is the rune U+FFFD of length 1
+func decodeRune( str string ) (rune, int) {
+  for _, c := range str { return c, utf8meter( str ) }
+
+  return 0xFFFD, 0
+}
+
+// lastRune returns the last rune of str
+func lastRune( str string ) rune {
+  i := len( str ) - 1
+  for ; i > 0 && i > len( str ) - utf8Max && (str[i] & 0xC0) == 0x80; i-- {}
+
+  if c, n := decodeRune( str[i:] ); i + n == len( str ) { return c }
+  return 0xFFFD
+}
+
+// bytesToString returns a string that shares the memory of b
+func bytesToString( b []byte ) string {
+  return *(*string)( unsafe.Pointer( &b ) )
+}
+
 func countCharDigits( str string ) int {
   for i, c := range str {
     if isDigit( c ) == false { return i }
@@ -58,6 +249,8 @@ func countCharDigits( str string ) int {
 }
 
 //////////////////////  from github.com/golang/go/src/unicode/utf8 //////////////////////
+const utf8Max = 4 // bytes of the widest rune
+
 const (
   t1 = 0x00   // 0000 0000
   tx = 0x80   // 1000 0000
@@ -0,0 +1,318 @@
+package regexp4
+
+import "unicode/utf8"
+
+// The lazy DFA counts the matches of the Pike program without catches. A state
+// is the ordered list of threads of the Pike VM at a position, with the same
+// priority, so each match ends where the VM would end it. The states are made
+// when a search reaches them, one rune at a time, and kept in a bounded cache
+// of the Matcher. The catches are found by the capturing engine only when they
+// are read.
+
+const (
+  dfaStates = 1024 // states in the cache, a full cache is flushed
+  dfaBytes  = 16   // bytes searched by state made, less is a cache thrash
+  dfaText   = 256  // bytes of the shortest text searched by the DFA
+)
+
+const (
+  dfaNone  uint8 = iota // no match ended before the last rune
+  dfaMatch              // a match ended before the last rune
+  dfaEmpty              // an empty match ended before the last rune
+)
+
+// dfaThread is a consume or end instruction of the Pike program, k is the
+// rune of a literal track to match
+type dfaThread struct{ pc, k int }
+
+type dfaState struct {
+  threads []dfaThread
+  seed    bool  // a new attempt starts at the position
+  found   bool  // a match was found, no attempt starts again
+  match   uint8 // match that ended before the rune that leads to the state
+  final   bool  // a match ends at the end of the text
+
+  next    [256]*dfaState        // by ASCII or invalid byte
+  runes   map[rune]*dfaState    // by multibyte rune
+}
+
+// dfaCache is the cache of states of a Matcher for its Regexp
+type dfaCache struct {
+  re     *Regexp
+  states map[string]*dfaState
+  start  [2]*dfaState // without threads, with and without seed
+  made   int          // states made in the search
+  init   int          // position where the search started
+  marks  []int
+  gen    int
+  key    []byte
+  list   []dfaThread
+}
+
+// genDFA reports in r.dfa if the Pike program can run on the lazy DFA: the
+// anchors and "#~" need the positions of the text, and the literals must be
+// valid UTF-8 to match a whole rune at a time
+func (r *Regexp) genDFA(){
+  if r.pike == nil || (r.mods & modFwrByChar) > 0 { return }
+
+  for _, in := range r.pike {
+    switch in.op {
+    case pikeAssert: return
+    case pikeConsume:
+      asm := &r.asm[ in.asm ]
+      if isLiteral( asm ) && (asm.re.mods & modCommunism) == 0 && !utf8.ValidString( asm.re.str ) { return }
+    }
+  }
+
+  r.dfa = true
+}
+
+// isLiteral reports if the track matches its text, as the default of match
+func isLiteral( asm *raptorASM ) bool {
+  return asm.inst == asmSimple || asm.inst == asmUTF8
+}
+
+// count is scan for the searches that only need the number of matches, it
+// runs the lazy DFA and leaves the catches to capture. The short texts do not
+// pay the cache, and a search with a step limit or a context keeps the engine
+// that records its matches before it stops
+func (r *Matcher) count( txt string, n int ) int {
+  if r.Regexp == nil || !r.dfa || len( txt ) < dfaText || r.limit > 0 || r.ctx != nil {
+    return r.scan( txt, n )
+  }
+  if !r.reset( txt ) { return 0 }
+
+  result, ok := r.dfaScan( txt, n )
+  if !ok { return r.scan( txt, n ) }
+
+  r.pending, r.want = result > 0 && r.err == nil, n
+  return result
+}
+
+// capture runs the last search of count again with the capturing engine, to
+// record its matches and catches
+func (r *Matcher) capture(){
+  if !r.pending { return }
+
+  r.pending = false
+  b := r.bytes
+  r.scan( r.txt, r.want )
+  r.bytes = b
+}
+
+// dfaScan is the scan loop of the lazy DFA, ok is false when the cache thrashes
+func (r *Matcher) dfaScan( txt string, n int ) (result int, ok bool) {
+  d := r.dfaCache()
+  d.made, d.init = 0, 0
+
+  loops, lines := r.end, (r.mods & modMultiline) > 0
+  if (r.mods & modAlpha) > 0 && !lines { loops = 1 }
+
+  for i := 0; i < loops; {
+    end, empty, found, ok := r.dfaRun( d, i, loops )
+    if !ok { return 0, false }
+    if !found { return r.result, true }
+
+    if (r.mods & modLonley) > 0 || ((r.mods & modOmega) > 0 && !lines) { r.result = 1; return 1, true
+    } else if empty { i = end + utf8meter( txt[end:] ); r.result++
+    } else {          i = end;                          r.result++ }
+
+    if r.result == n { break }
+  }
+
+  return r.result, true
+}
+
+// dfaCache returns the cache of the Matcher, empty when it was for other Regexp
+func (r *Matcher) dfaCache() *dfaCache {
+  if r.states == nil || r.states.re != r.Regexp {
+    r.states = &dfaCache{ re: r.Regexp, marks: make( []int, len( r.pike ) ) }
+    r.states.flush()
+  }
+
+  return r.states
+}
+
+func (d *dfaCache) flush(){
+  d.states = make( map[string]*dfaState )
+  d.start  = [2]*dfaState{}
+}
+
+// dfaRun is pikeRun over the states of the DFA, it finds the end of the first
+// match that starts from i and if it is empty
+func (r *Matcher) dfaRun( d *dfaCache, i, loops int ) (end int, empty, found, ok bool) {
+  s := r.dfaStart( d, i < loops && !r.skipLine( i ) )
+
+  for p := i; ; {
+    if !r.step() { return 0, false, false, true }
+
+    if len( s.threads ) == 0 {
+      if s.found || p >= loops { return end, empty, found, true }
+
+      if loops > 1 && s.seed {
+        if p = r.candidate( r.txt, p ); p >= loops { return end, empty, found, true }
+        s = r.dfaStart( d, !r.skipLine( p ) )
+      }
+    }
+
+    if p >= r.end {
+      if s.final { return p, false, true, true }
+      return end, empty, found, true
+    }
+
+    c, w := rune( r.txt[p] ), 1
+    if c >= utf8.RuneSelf { c, w = decodeRune( r.txt[p:] ) }
+
+    var next *dfaState
+    if w == 1 { next = s.next[ r.txt[p] ]
+    } else    { next = s.runes[ c ] }
+
+    if next == nil {
+      if next = r.dfaNext( d, s, r.txt[p:p + w], p ); next == nil { return 0, false, false, false }
+    }
+
+    if next.match != dfaNone { end, empty, found = p, next.match == dfaEmpty, true }
+    s, p = next, p + w
+  }
+}
+
+// dfaStart returns the state without threads of an attempt
+func (r *Matcher) dfaStart( d *dfaCache, seed bool ) *dfaState {
+  k := 0
+  if seed { k = 1 }
+
+  if d.start[k] == nil { d.start[k] = d.state( &dfaState{ seed: seed } ) }
+
+  return d.start[k]
+}
+
+// dfaNext makes the state that follows s by the rune tok at the position p, as
+// a step of pikeRun without catches, it returns nil when the cache thrashes or
+// a track does not match a whole rune
+func (r *Matcher) dfaNext( d *dfaCache, s *dfaState, tok string, p int ) *dfaState {
+  if len( d.states ) >= dfaStates {
+    if p - d.init < d.made * dfaBytes { return nil }
+
+    d.flush()
+    d.init, d.made = p, 0
+  }
+
+  d.gen++
+  list := append( d.list[:0], s.threads... )
+  for _, t := range list {
+    if t.k == 0 { d.marks[ t.pc ] = d.gen }
+  }
+
+  fresh := len( list )
+  if s.seed { list = d.closure( r.pike, list, 0 ) }
+
+  c, _ := decodeRune( tok )
+  omega := (r.mods & modOmega) == 0 || ((r.mods & modMultiline) > 0 && tok == "\n")
+  next  := &dfaState{}
+
+  d.gen++
+  for k, t := range list {
+    in := &r.pike[ t.pc ]
+    if in.op == pikeEnd {
+      if !omega { continue }
+
+      next.match = dfaMatch
+      if k >= fresh { next.match = dfaEmpty }
+      break
+    }
+
+    asm := &r.asm[ in.asm ]
+    if isLiteral( asm ) {
+      lit, n := nthRune( asm.re.str, t.k )
+      if (asm.re.mods & modCommunism) > 0 { if !equalFold( lit, c ) { continue }
+      } else if asm.re.str[n:n + utf8.RuneLen( lit )] != tok { continue }
+
+      if t.k + 1 < utf8.RuneCountInString( asm.re.str ) {
+        next.threads = d.addLiteral( next.threads, dfaThread{ t.pc, t.k + 1 } )
+        continue
+      }
+    } else {
+      forward := 0
+      if !r.match( in.asm, tok, &forward ) { continue }
+      if forward != len( tok ) { return nil }
+    }
+
+    next.threads = d.closure( r.pike, next.threads, t.pc + 1 )
+  }
+
+  d.list = list
+  next.found = s.found || next.match != dfaNone
+  if !next.found {
+    if (r.mods & modAlpha) == 0 { next.seed = true
+    } else                      { next.seed = (r.mods & modMultiline) > 0 && tok == "\n" }
+  }
+
+  for _, t := range next.threads {
+    if r.pike[ t.pc ].op == pikeEnd { next.final = true; break }
+  }
+
+  next = d.state( next )
+  if len( tok ) == 1 { s.next[ tok[0] ] = next
+  } else {
+    if s.runes == nil { s.runes = make( map[rune]*dfaState ) }
+    s.runes[c] = next
+  }
+
+  return next
+}
+
+// state returns the state of the cache equal to s, or adds s
+func (d *dfaCache) state( s *dfaState ) *dfaState {
+  d.key = append( d.key[:0], dfaFlags( s ) )
+  for _, t := range s.threads {
+    d.key = append( d.key, byte( t.pc ), byte( t.pc >> 8 ), byte( t.k ), byte( t.k >> 8 ), byte( t.k >> 16 ), byte( t.k >> 24 ) )
+  }
+
+  if old, ok := d.states[ string( d.key ) ]; ok { return old }
+
+  d.states[ string( d.key ) ] = s
+  d.made++
+  return s
+}
+
+func dfaFlags( s *dfaState ) byte {
+  flags := s.match << 2
+  if s.seed  { flags |= 1 }
+  if s.found { flags |= 2 }
+
+  return flags
+}
+
+// closure adds the consume and end instructions that pc reaches by jumps,
+// splits and catches, in order of priority, once each
+func (d *dfaCache) closure( prog []pikeInst, list []dfaThread, pc int ) []dfaThread {
+  if d.marks[ pc ] == d.gen { return list }
+  d.marks[ pc ] = d.gen
+
+  switch in := &prog[ pc ]; in.op {
+  case pikeJmp           : return d.closure( prog, list, in.x )
+  case pikeSplit         : return d.closure( prog, d.closure( prog, list, in.x ), in.y )
+  case pikeOpen, pikeClose: return d.closure( prog, list, pc + 1 )
+  }
+
+  return append( list, dfaThread{ pc, 0 } )
+}
+
+// addLiteral adds a thread inside a literal, once
+func (d *dfaCache) addLiteral( list []dfaThread, t dfaThread ) []dfaThread {
+  for _, l := range list {
+    if l == t { return list }
+  }
+
+  return append( list, t )
+}
+
+// nthRune returns the rune k of str and its position
+func nthRune( str string, k int ) (rune, int) {
+  for n, c := range str {
+    if k == 0 { return c, n }
+    k--
+  }
+
+  return utf8.RuneError, len( str )
+}
@@ -0,0 +1,97 @@
+//go:build ignore
+// +build ignore
+
+// gen_tables writes tables.go, the Unicode tables of the package, from the
+// tables of the Go release that runs it
+//
+//   go run gen_tables.go
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "io/ioutil"
+  "log"
+  "sort"
+  "unicode"
+)
+
+func main() {
+  var buf bytes.Buffer
+  fmt.Fprintf( &buf, "// Code generated by gen_tables.go; DO NOT EDIT.\n\n" )
+  fmt.Fprintf( &buf, "package regexp4\n\n" )
+  fmt.Fprintf( &buf, "// unicodeVersion is the version of the tables\n" )
+  fmt.Fprintf( &buf, "const unicodeVersion = %q\n\n", unicode.Version )
+
+  table( &buf, "tableLetter", "letters, general category L",         unicode.Letter )
+  table( &buf, "tableDigit" , "decimal digits, general category Nd", unicode.Digit  )
+  table( &buf, "tableSpace" , "runes of the property White_Space",   unicode.White_Space )
+  table( &buf, "tableBlank" , "tab and the space separators, Zs",    unicode.Zs, tab )
+
+  folds( &buf )
+
+  classes( &buf, "general category", unicode.Categories )
+  classes( &buf, "script",           unicode.Scripts    )
+
+  fmt.Fprintf( &buf, "// unicodeClasses are the tables of :p{name} by name\n" )
+  fmt.Fprintf( &buf, "var unicodeClasses = map[string]runeTable{\n" )
+  for _, name := range append( sorted( unicode.Categories ), sorted( unicode.Scripts )... ) {
+    fmt.Fprintf( &buf, "  %-26q: class%s,\n", name, name )
+  }
+  fmt.Fprintf( &buf, "}\n" )
+
+  if err := ioutil.WriteFile( "tables.go", append( bytes.TrimRight( buf.Bytes(), "\n" ), '\n' ), 0644 ); err != nil { log.Fatal( err ) }
+}
+
+// folds writes the simple case folding as the next rune of each orbit, the
+// runes without a fold are left out
+func folds( buf *bytes.Buffer ){
+  fmt.Fprintf( buf, "// tableFold is the simple case folding, the next rune of the orbit of each\n" )
+  fmt.Fprintf( buf, "// rune that has one\nvar tableFold = foldTable{" )
+  for c, i := rune( 0 ), 0; c <= unicode.MaxRune; c++ {
+    f := unicode.SimpleFold( c )
+    if f == c { continue }
+
+    if i % 5 == 0 { fmt.Fprintf( buf, "\n " ) }
+    fmt.Fprintf( buf, " { 0x%04X, 0x%04X },", c, f )
+    i++
+  }
+
+  fmt.Fprintf( buf, "\n}\n\n" )
+}
+
+func sorted( m map[string]*unicode.RangeTable ) (names []string) {
+  for name := range m { names = append( names, name ) }
+  sort.Strings( names )
+  return
+}
+
+func classes( buf *bytes.Buffer, doc string, m map[string]*unicode.RangeTable ){
+  for _, name := range sorted( m ) {
+    table( buf, "class" + name, "runes of the " + doc + " " + name, m[name] )
+  }
+}
+
+var tab = &unicode.RangeTable{ R16: []unicode.Range16{ { Lo: '\t', Hi: '\t', Stride: 1 } } }
+
+// table writes the union of tabs as a runeTable of merged ranges
+func table( buf *bytes.Buffer, name, doc string, tabs ...*unicode.RangeTable ){
+  var ranges [][2]rune
+  for c := rune( 0 ); c <= unicode.MaxRune; c++ {
+    if !unicode.In( c, tabs... ) { continue }
+
+    if n := len( ranges ); n > 0 && ranges[n - 1][1] == c - 1 {
+      ranges[n - 1][1] = c
+    } else {
+      ranges = append( ranges, [2]rune{ c, c } )
+    }
+  }
+
+  fmt.Fprintf( buf, "// %s are the %s\nvar %s = runeTable{", name, doc, name )
+  for i, r := range ranges {
+    if i % 5 == 0 { fmt.Fprintf( buf, "\n " ) }
+    fmt.Fprintf( buf, " { 0x%04X, 0x%04X },", r[0], r[1] )
+  }
+
+  fmt.Fprintf( buf, "\n}\n\n" )
+}
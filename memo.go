@@ -0,0 +1,193 @@
+package regexp4
+
+import "errors"
+
+// The memoized backtracking records in a bitset each state (track, position)
+// where trekking failed, the state is not explored again in the same search.
+// A state is memoized only when its result depends on the position alone: no
+// backreference can be matched after it, and the loops around it do not count
+// iterations nor ask if the current iteration is empty.
+
+// ErrNoMemo is returned by WithMemo when some choice of the expression can not
+// be memoized, its worst case stays exponential
+var ErrNoMemo = errors.New( "regexp4: memoization unavailable" )
+
+const maxMemo = 1 << 25 // bits of the memo of a search, longer texts search without it
+
+// WithMemo returns a copy of the expression that always backtracks, without
+// the Pike VM nor the lazy DFA, and remembers the states where it failed, it
+// reports ErrNoMemo when some of them can not be remembered
+func (r *Regexp) WithMemo() (*Regexp, error) {
+  c := *r
+  c.pike, c.dfa = nil, false
+  if len( c.asm ) == 0 || c.genMemo() { return &c, nil }
+
+  return &c, ErrNoMemo
+}
+
+// genMemo gives a slot of the bitset to each track that can be memoized, it
+// reports if every choice of the backtracking goes to a memoized state
+func (r *Regexp) genMemo() bool {
+  backref := -1
+  for index := range r.asm {
+    if r.asm[ index ].inst == asmBackref { backref = index }
+  }
+
+  r.memo, r.memos = make( []int, len( r.asm ) ), 0
+  for index := range r.asm {
+    r.memo[ index ] = -1
+    if index > backref && r.memoizable( index ) { r.memo[ index ] = r.memos; r.memos++ }
+  }
+
+  for index := range r.asm {
+    for _, next := range r.choices( index ) {
+      if !r.covered( next ) { return false }
+    }
+  }
+
+  return true
+}
+
+// memoizable walks out of the containers of the track at index to know if its
+// continuation is the same whenever trekking reaches it at a position. The end
+// of an element of a path is reached from the element before it or from any
+// of them, the text of the iteration that reaches it is unknown
+func (r *Regexp) memoizable( index int ) bool {
+  switch r.asm[ index ].inst {
+  case asmPathEle, asmPathEnd: return false
+  }
+
+  child, least, exact := index, 0, true
+  for g := index - 1; g >= 0; g-- {
+    asm := &r.asm[ g ]
+    switch asm.inst {
+    case asmPathEle:
+      if child >= asm.close { continue }
+      least, exact = r.before( g + 1, child, least, exact )
+      child = g
+    case asmPath:
+      if child > asm.close { continue }
+      child = g
+    case asmGroup, asmHook, asmAhead, asmBehind:
+      if child > asm.close { continue }
+      least, exact = r.before( g + 1, child, least, exact )
+
+      switch {
+      case asm.inst == asmBehind                : return false
+      case asm.inst == asmAhead                 : return true
+      case (asm.re.mods & modPossessive) > 0    : return true
+      case asm.re.loopsMin > 1                  : return false
+      case asm.re.loopsMax > 1 && asm.re.loopsMax < inf: return false
+      case asm.re.loopsMax > 1:
+        if r.hasBackref( g, asm.close ) || (least == 0 && !exact) { return false }
+        exact = false
+      }
+
+      child = g
+    }
+  }
+
+  return true
+}
+
+// before adds the width of the tracks from init to index, least is the text
+// they match at least and exact is true while it is always none
+func (r *Regexp) before( init, index, least int, exact bool ) (int, bool) {
+  if init == index { return least, exact }
+
+  a, b := r.width( init ), r.width( index )
+  return least + a.min - b.min, exact && a.max < inf && a.max == b.max
+}
+
+func (r *Regexp) hasBackref( init, end int ) bool {
+  for ; init < end; init++ {
+    if r.asm[ init ].inst == asmBackref { return true }
+  }
+
+  return false
+}
+
+// choices returns the tracks where the backtracking goes back to from the
+// track at index
+func (r *Regexp) choices( index int ) (next []int) {
+  asm := &r.asm[ index ]
+  if asm.inst == asmPath {
+    for ele := index + 1; r.asm[ ele ].inst == asmPathEle; ele = r.asm[ ele ].close {
+      next = append( next, ele + 1 )
+    }
+
+    return
+  }
+
+  if asm.re.loopsMin == asm.re.loopsMax || (asm.re.mods & modPossessive) > 0 { return }
+
+  switch asm.inst {
+  case asmGroup, asmHook: return []int{ index + 1, asm.close + 1 }
+  case asmPathEle, asmPathEnd, asmGroupEnd, asmHookEnd, asmLookEnd, asmEnd,
+       asmAhead, asmBehind, asmAnchor: return
+  }
+
+  return []int{ asm.close + 1 }
+}
+
+// covered reports if trekking at index is memoized or only resumes to memoized
+// tracks
+func (r *Regexp) covered( index int ) bool {
+  if r.memo[ index ] >= 0 { return true }
+
+  switch r.asm[ index ].inst {
+  case asmLookEnd: return true
+  case asmPathEle, asmPathEnd:
+    for g := index - 1; g >= 0; g-- {
+      if r.asm[ g ].inst == asmPath && r.asm[ g ].close >= index { return r.covered( r.asm[ g ].close + 1 ) }
+    }
+  case asmGroupEnd, asmHookEnd:
+    for g := index - 1; g >= 0; g-- {
+      if r.asm[ g ].close != index { continue }
+      if r.asm[ g ].re.loopsMax > 1 && r.memo[ g + 1 ] < 0 { return false }
+      return r.covered( index + 1 )
+    }
+  }
+
+  return false
+}
+
+// resetMemo clears the bitset for a search over txt, it reports false when the
+// text is too long to memoize
+func (r *Matcher) resetMemo() bool {
+  bits := r.memos * (r.end + 1)
+  if bits > maxMemo { return false }
+
+  words := (bits + 63) / 64
+  if cap( r.visited ) < words { r.visited = make( []uint64, words ) }
+
+  r.visited = r.visited[:words]
+  for i := range r.visited { r.visited[i] = 0 }
+
+  return true
+}
+
+// trekking is trek, when the state at index is memoized it is only explored
+// the first time. Every recursion of the engine passes here, so it stops the
+// search with ErrDepthLimit after maxDepth nested calls
+func (r *Matcher) trekking( index, k int ) bool {
+  if r.depth >= maxDepth { r.err = ErrDepthLimit; return false }
+
+  r.depth++
+  ok := r.memoTrek( index, k )
+  r.depth--
+
+  return ok
+}
+
+func (r *Matcher) memoTrek( index, k int ) bool {
+  if !r.memoized || r.memo[ index ] < 0 { return r.trek( index, k ) }
+
+  bit := r.memo[ index ] * (r.end + 1) + r.pos
+  if r.visited[ bit >> 6 ] & (1 << uint( bit & 63 )) != 0 { return false }
+
+  if r.trek( index, k ) { return true }
+
+  if r.err == nil { r.visited[ bit >> 6 ] |= 1 << uint( bit & 63 ) }
+  return false
+}
@@ -0,0 +1,343 @@
+package regexp4
+
+// The Pike VM runs the expressions without backreferences in one pass over the
+// text, all the paths of the search advance together as threads ordered by
+// the priority of the backtracking engine, so the match and the catches are
+// the same, and the time is O(len(program) x len(text)). The lookarounds, the
+// possessive loops and the loops of groups that can match no text keep the
+// backtracking engine, without backreferences it stops with ErrStepLimit
+// after fallbackSteps by track and byte of the text.
+
+const (
+  pikeConsume uint8 = iota // match the track asm and go to the next instruction
+  pikeSplit                // go to x, and with less priority to y
+  pikeJmp                  // go to x
+  pikeOpen                 // open the catch of the hook asm
+  pikeClose                // close the catch of the hook asm
+  pikeAssert               // test the anchor asm
+  pikeEnd                  // the match ends
+)
+
+const (
+  maxPike    = 4096 // instructions of a lowered program, more use backtracking
+  pikeEvents = 1024 // events of the arena over the live ones before compactEvents
+)
+
+// pikeInst is an instruction of the lowered program of the Pike VM
+type pikeInst struct {
+  op   uint8
+  x, y int
+  asm  int // track of the program of the Regexp
+}
+
+// pikeThread is a path of the search, it waits wait bytes inside the rune or
+// text matched by pc before it goes on
+type pikeThread struct {
+  pc, wait int
+  init     int // position of the attempt
+  event    int // last event of its catches in Matcher.events, -1 when none
+}
+
+// pikeEvent is the open or the close of a catch, the events of a thread are a
+// list linked by prev that is shared with the threads it comes from
+type pikeEvent struct {
+  op        uint8
+  asm, pos  int
+  prev      int
+}
+
+type pikeGen struct {
+  r    *Regexp
+  prog []pikeInst
+  ok   bool
+}
+
+// genPike lowers the program to the Pike VM, or leaves r.pike nil when the
+// expression needs the backtracking engine
+func (r *Regexp) genPike(){
+  g := &pikeGen{ r: r, ok: true }
+  g.seq( 0 )
+
+  if g.ok { r.pike = g.prog }
+}
+
+func (g *pikeGen) emit( in pikeInst ) int {
+  if len( g.prog ) >= maxPike { g.ok = false }
+
+  g.prog = append( g.prog, in )
+  return len( g.prog ) - 1
+}
+
+// seq lowers the tracks from index to the end of its path, group or hook
+func (g *pikeGen) seq( index int ){
+  for ; g.ok; index = g.r.asm[ index ].close + 1 {
+    switch g.r.asm[ index ].inst {
+    case asmEnd: g.emit( pikeInst{ op: pikeEnd } ); return
+    case asmPathEle, asmPathEnd, asmGroupEnd, asmHookEnd: return
+    case asmAhead, asmBehind, asmBackref: g.ok = false; return
+    case asmAnchor:
+      if g.r.asm[ index ].re.loopsMin > 0 { g.emit( pikeInst{ op: pikeAssert, asm: index } ) }
+    case asmPath : g.path( index )
+    case asmGroup: g.loop( index, func(){ g.seq( index + 1 ) } )
+    case asmHook :
+      g.emit( pikeInst{ op: pikeOpen, asm: index } )
+      g.loop( index, func(){ g.seq( index + 1 ) } )
+      g.emit( pikeInst{ op: pikeClose, asm: index } )
+    default: g.loop( index, func(){ g.emit( pikeInst{ op: pikeConsume, asm: index } ) } )
+    }
+  }
+}
+
+// path lowers the alternatives of the path at index, the first has priority
+func (g *pikeGen) path( index int ){
+  var jumps []int
+  for ele := index + 1; g.ok && g.r.asm[ ele ].inst == asmPathEle; ele = g.r.asm[ ele ].close {
+    split := -1
+    if g.r.asm[ g.r.asm[ ele ].close ].inst == asmPathEle {
+      split = g.emit( pikeInst{ op: pikeSplit } )
+      g.prog[ split ].x = len( g.prog )
+    }
+
+    g.seq( ele + 1 )
+
+    if split >= 0 {
+      jumps = append( jumps, g.emit( pikeInst{ op: pikeJmp } ) )
+      g.prog[ split ].y = len( g.prog )
+    }
+  }
+
+  for _, j := range jumps { g.prog[ j ].x = len( g.prog ) }
+}
+
+// loop repeats body as the loops of the track at index: the minimum of copies
+// and the optional copies, greedy or lazy
+func (g *pikeGen) loop( index int, body func() ){
+  rexp := &g.r.asm[ index ].re
+  if (rexp.mods & modPossessive) > 0 { g.ok = false; return }
+
+  inst := g.r.asm[ index ].inst
+  if (inst == asmGroup || inst == asmHook) && rexp.loopsMax > 1 && g.r.width( index + 1 ).min == 0 {
+    g.ok = false
+    return
+  }
+
+  for i := 0; g.ok && i < rexp.loopsMin; i++ { body() }
+
+  lazy := (rexp.mods & modLazy) > 0
+  if rexp.loopsMax >= inf {
+    split := g.emit( pikeInst{ op: pikeSplit } )
+    body()
+    g.emit( pikeInst{ op: pikeJmp, x: split } )
+    g.branch( split, split + 1, len( g.prog ), lazy )
+    return
+  }
+
+  var splits []int
+  for i := rexp.loopsMin; g.ok && i < rexp.loopsMax; i++ {
+    splits = append( splits, g.emit( pikeInst{ op: pikeSplit } ) )
+    body()
+  }
+
+  for _, split := range splits { g.branch( split, split + 1, len( g.prog ), lazy ) }
+}
+
+// branch points the split to one more iteration or to the exit of the loop
+func (g *pikeGen) branch( split, iteration, exit int, lazy bool ){
+  if lazy { g.prog[ split ].x, g.prog[ split ].y = exit, iteration
+  } else  { g.prog[ split ].x, g.prog[ split ].y = iteration, exit }
+}
+
+// pikeScan is the scan loop of the Pike VM
+func (r *Matcher) pikeScan( txt string, n int ) int {
+  loops, lines := r.end, (r.mods & modMultiline) > 0
+  if (r.mods & modAlpha) > 0 && !lines { loops = 1 }
+
+  for i := 0; i < loops; {
+    ocindex := r.catchIndex
+    init, end, ok := r.pikeRun( i, loops )
+    if !ok { return r.result }
+
+    r.matches = append( r.matches, matchInfo{ init, end, ocindex } )
+    if (r.mods & modLonley) > 0 || ((r.mods & modOmega) > 0 && !lines) { r.result = 1; return 1
+    } else if (r.mods & modFwrByChar) > 0 || end == init { i = init + utf8meter( txt[init:] ); r.result++
+    } else {   i = end;                                                    r.result++; }
+
+    if r.result == n { return r.result }
+  }
+
+  return r.result
+}
+
+// pikeRun finds the first match that starts from i, the attempts start at the
+// positions that the scan loop would try, before loops
+func (r *Matcher) pikeRun( i, loops int ) (init, end int, found bool) {
+  if len( r.marks ) < len( r.pike ) {
+    r.marks = make( []int, len( r.pike ) )
+    r.waits = make( []int, len( r.pike ) * utf8Max )
+  }
+
+  if len( r.opened ) < len( r.asm ) { r.opened = make( []int, len( r.asm ) ) }
+
+  for k := range r.marks { r.marks[k] = -1 }
+  for k := range r.waits { r.waits[k] = -1 }
+  r.events = r.events[:0]
+
+  clist, nlist := r.threads[0][:0], r.threads[1][:0]
+  defer func(){ r.threads[0], r.threads[1] = clist, nlist }()
+
+  match, limit := pikeThread{ event: -1 }, pikeEvents
+  next := i
+  for p := i; ; p++ {
+    if !found && p == next && p < loops {
+      next = p + utf8meter( r.txt[p:] )
+      if !r.skipLine( p ) { clist = r.addThread( clist, 0, p, pikeThread{ init: p, event: -1 } ) }
+    }
+
+    if len( clist ) == 0 {
+      if found || next >= loops { break }
+
+      next = r.candidate( r.txt, next )
+      p    = next - 1
+      continue
+    }
+
+  threads:
+    for _, t := range clist {
+      if !r.step() { return 0, 0, false }
+
+      if t.wait > 0 {
+        if t.wait == 1 { nlist = r.addThread( nlist, t.pc + 1, p + 1, t )
+        } else         { nlist = r.addWait  ( nlist, t, t.wait - 1, p + 1 ) }
+
+        continue
+      }
+
+      in := &r.pike[ t.pc ]
+      switch in.op {
+      case pikeEnd:
+        if r.pos = p; !r.atOmega() { continue }
+
+        found, match, end = true, t, p
+        break threads
+      case pikeConsume:
+        forward := 0
+        if r.pos = p; r.atEnd() || !r.match( in.asm, r.txt[p:], &forward ) { continue }
+
+        if forward == 1 { nlist = r.addThread( nlist, t.pc + 1, p + 1, t )
+        } else          { nlist = r.addWait  ( nlist, t, forward - 1, p + 1 ) }
+      }
+    }
+
+    if p >= r.end { break }
+    clist, nlist = nlist, clist[:0]
+
+    if len( r.events ) > limit {
+      r.compactEvents( clist, &match )
+      limit = 2 * len( r.events ) + pikeEvents
+    }
+  }
+
+  if found { r.pikeCatches( match.event ) }
+  return match.init, end, found
+}
+
+// addThread adds t at pc to the list, following the jumps, splits, catches and
+// anchors at the position pos, a pc already reached at pos by a thread of more
+// priority is dropped
+func (r *Matcher) addThread( list []pikeThread, pc, pos int, t pikeThread ) []pikeThread {
+  if r.marks[ pc ] == pos { return list }
+  r.marks[ pc ] = pos
+
+  in := &r.pike[ pc ]
+  switch in.op {
+  case pikeJmp  : return r.addThread( list, in.x, pos, t )
+  case pikeSplit:
+    list = r.addThread( list, in.x, pos, t )
+    return r.addThread( list, in.y, pos, t )
+  case pikeOpen, pikeClose:
+    r.events = append( r.events, pikeEvent{ in.op, in.asm, pos, t.event } )
+    t.event  = len( r.events ) - 1
+    return r.addThread( list, pc + 1, pos, t )
+  case pikeAssert:
+    if r.pos = pos; !r.anchor( in.asm ) { return list }
+    return r.addThread( list, pc + 1, pos, t )
+  }
+
+  t.pc, t.wait = pc, 0
+  return append( list, t )
+}
+
+// compactEvents moves the events of the threads of the list and of the match
+// to the spare arena, the events of the dead threads are dropped so the arena
+// does not grow with the text
+func (r *Matcher) compactEvents( list []pikeThread, match *pikeThread ){
+  if cap( r.remap ) < len( r.events ) { r.remap = make( []int, len( r.events ) ) }
+
+  r.remap = r.remap[:len( r.events )]
+  for k := range r.remap { r.remap[k] = -1 }
+
+  live := r.spare[:0]
+  for k := range list { list[k].event, live = r.keepEvents( list[k].event, live ) }
+  match.event, live = r.keepEvents( match.event, live )
+
+  r.events, r.spare = live, r.events
+}
+
+// keepEvents copies to live the events of the list that ends at event which
+// are not copied yet, it returns the new index of event
+func (r *Matcher) keepEvents( event int, live []pikeEvent ) (int, []pikeEvent) {
+  base := len( r.stack )
+  for ; event >= 0 && r.remap[ event ] < 0; event = r.events[ event ].prev { r.stack = append( r.stack, event ) }
+
+  prev := -1
+  if event >= 0 { prev = r.remap[ event ] }
+
+  for k := len( r.stack ) - 1; k >= base; k-- {
+    e := r.events[ r.stack[k] ]
+    e.prev = prev
+    live   = append( live, e )
+    prev = len( live ) - 1
+    r.remap[ r.stack[k] ] = prev
+  }
+
+  r.stack = r.stack[:base]
+  return prev, live
+}
+
+// addWait adds t to the list, inside the rune or text of its pc with wait
+// bytes left
+func (r *Matcher) addWait( list []pikeThread, t pikeThread, wait, pos int ) []pikeThread {
+  key := t.pc * utf8Max + wait
+  if wait >= utf8Max || r.waits[ key ] == pos {
+    if wait < utf8Max { return list }
+  } else {
+    r.waits[ key ] = pos
+  }
+
+  t.wait = wait
+  return append( list, t )
+}
+
+// pikeCatches writes the catches of the events of a match from its last event,
+// as catcher and exitGroup do along the path
+func (r *Matcher) pikeCatches( event int ){
+  base := len( r.stack )
+  for ; event >= 0; event = r.events[ event ].prev { r.stack = append( r.stack, event ) }
+
+  for k := len( r.stack ) - 1; k >= base; k-- {
+    e := &r.events[ r.stack[k] ]
+    if e.op == pikeClose {
+      r.catches[ r.opened[ e.asm ] ].end = e.pos
+      continue
+    }
+
+    c := catchInfo{ e.pos, e.pos, r.asm[ e.asm ].id }
+    if r.catchIndex < len( r.catches ) { r.catches[ r.catchIndex ] = c
+    } else                             { r.catches = append( r.catches, c ) }
+
+    r.opened[ e.asm ] = r.catchIndex
+    r.catchIndex++
+  }
+
+  r.stack = r.stack[:base]
+}
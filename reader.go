@@ -0,0 +1,150 @@
+package regexp4
+
+import "io"
+
+const readerChunk = 4096 // minimum of bytes read from a stream at once
+
+// MatchReader searches the text of rd, returns the number of matches, the
+// catches are discarded. A read error other than io.EOF ends the text and is
+// kept for Err. The streams always backtrack, without the literal filter nor
+// the Pike VM of MatchString, so a long stream can take much more time than
+// the same text as a string
+func (r *Matcher) MatchReader( rd io.RuneReader ) int {
+  return r.scanReader( rd, -1, nil )
+}
+
+// FindReaderIndex returns the start and end byte offsets of the first match in
+// the text of rd, or nil when there is no match, as MatchReader
+func (r *Matcher) FindReaderIndex( rd io.RuneReader ) (loc []int) {
+  r.scanReader( rd, 1, func( init, end int ){ loc = []int{ init, end } } )
+  return
+}
+
+// FindAllReaderIndex returns the start and end byte offsets of the first n
+// matches in the text of rd, all of them when n < 0, as MatchReader
+func (r *Matcher) FindAllReaderIndex( rd io.RuneReader, n int ) (result [][]int) {
+  if n == 0 { return nil }
+
+  r.scanReader( rd, n, func( init, end int ){ result = append( result, []int{ init, end } ) } )
+  return
+}
+
+// scanReader is the scan loop of MatchString over a window of the stream, an
+// attempt that reaches the end of the window is repeated after reading more
+// text, and the text before the attempts is dropped except for the bytes a
+// lookbehind or an anchor can look at
+func (r *Matcher) scanReader( rd io.RuneReader, n int, found func( init, end int ) ) int {
+  var buf []byte
+  var err error
+  eof, keep := false, r.lookback()
+  r.txt, r.bytes, r.base, r.end, r.result = "", nil, 0, 0, 0
+  r.matches = r.matches[:0]
+  r.steps, r.budget, r.err, r.pending, r.memoized = 0, r.limit, nil, false, false
+  if r.catches == nil { r.catches = make( []catchInfo, 32 ) }
+  if r.Regexp == nil || len(r.asm) == 0 { return 0 }
+
+  lines := (r.mods & modMultiline) > 0
+  for forward, i := 0, 0; ; i += forward {
+    if keep >= 0 && i - keep > len( buf ) / 2 {
+      drop    := i - keep
+      buf      = buf[:copy( buf, buf[drop:] )]
+      r.base  += drop
+      i       -= drop
+    }
+
+    if i >= len( buf ) && !eof {
+      buf, err = readRunes( rd, buf )
+      eof      = err != nil
+      forward  = 0
+      continue
+    }
+
+    if i >= len( buf ) || ((r.mods & modAlpha) > 0 && !lines && r.base + i > 0) { break }
+
+    r.txt, r.end  = bytesToString( buf ), len( buf )
+    forward, r.pos = utf8meter( r.txt[i:] ), i
+    if r.skipLine( i ) {
+      forward = nextLine( r.txt[i:] )
+      continue
+    }
+
+    r.catchIndex, r.hitEnd = 1, false
+    ok := r.trekking( 0, -1 )
+    if r.err != nil { break }
+
+    if r.hitEnd && !eof {
+      buf, err = readRunes( rd, buf )
+      eof      = err != nil
+      forward  = 0
+      continue
+    }
+
+    if !ok { continue }
+
+    if found != nil { found( r.base + i, r.base + r.pos ) }
+    if (r.mods & modLonley) > 0 || ((r.mods & modOmega) > 0 && !lines) { r.result = 1; break
+    } else if (r.mods & modFwrByChar) > 0 || r.pos == i { r.result++
+    } else {   forward = r.pos - i;                       r.result++; }
+
+    if r.result == n { break }
+  }
+
+  r.txt, r.end, r.catchIndex = "", 0, 1
+  if r.err == nil && err != io.EOF { r.err = err }
+  return r.result
+}
+
+// lookback returns the bytes before an attempt that must be kept in the
+// window, or -1 when a lookbehind has no limit
+func (r *Regexp) lookback() int {
+  keep := utf8Max
+  for _, asm := range r.asm {
+    if asm.inst != asmBehind { continue }
+    if asm.width.max >= inf  { return -1 }
+    if asm.width.max + utf8Max > keep { keep = asm.width.max + utf8Max }
+  }
+
+  return keep
+}
+
+// readRunes appends to buf at least readerChunk bytes of rd or as many as buf
+// has, the invalid bytes are kept as one byte to preserve the offsets, err is
+// the error that ended rd
+func readRunes( rd io.RuneReader, buf []byte ) ([]byte, error) {
+  size := len( buf )
+  if size < readerChunk { size = readerChunk }
+
+  for end := len( buf ) + size; len( buf ) < end; {
+    c, n, err := rd.ReadRune()
+    if err != nil { return buf, err }
+
+    if c == '\uFFFD' && n == 1 {
+      buf = append( buf, 0xFF )
+    } else {
+      buf = append( buf, string( c )... )
+    }
+  }
+
+  return buf, nil
+}
+
+func (r *Regexp) MatchReader( rd io.RuneReader ) int {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.MatchReader( rd )
+}
+
+func (r *Regexp) FindReaderIndex( rd io.RuneReader ) []int {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.FindReaderIndex( rd )
+}
+
+func (r *Regexp) FindAllReaderIndex( rd io.RuneReader, n int ) [][]int {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.FindAllReaderIndex( rd, n )
+}
@@ -1,62 +1,165 @@
 package regexp4
 
+import (
+  "context"
+  "errors"
+  "sort"
+  "strings"
+  "sync"
+)
+
 const inf = 1073741824 // 2^30
 
+// ErrStepLimit is the error of a search that ran out of its step limit
+var ErrStepLimit = errors.New( "regexp4: step limit exceeded" )
+
+// ErrDepthLimit is the error of a search of the backtracking engine that
+// nested more than maxDepth calls, as an iteration of a group in each one
+var ErrDepthLimit = errors.New( "regexp4: backtracking depth exceeded" )
+
+const maxDepth = 1 << 16 // nested calls of trekking, the stack of the goroutine grows with them
+
+const ctxSteps = 1024 // steps between checks of the context of a search
+
+const fallbackSteps = 1024 // steps by track and byte of a search without backreferences that backtracks
+
 const (
-  modAlpha      uint8 = 1
-  modOmega      uint8 = 2
-  modLonley     uint8 = 4
-  modFwrByChar  uint8 = 8
-  modCommunism  uint8 = 16
-  modNegative   uint8 = 128
-  modPositive   uint8 = ^modNegative
-  modCapitalism uint8 = ^modCommunism
+  modAlpha      uint16 = 1
+  modOmega      uint16 = 2
+  modLonley     uint16 = 4
+  modFwrByChar  uint16 = 8
+  modCommunism  uint16 = 16
+  modLazy       uint16 = 32
+  modPossessive uint16 = 64
+  modNegative   uint16 = 128
+  modMultiline  uint16 = 256
+  modUnicode    uint16 = 512
+  modPositive   uint16 = ^modNegative
+  modCapitalism uint16 = ^modCommunism
+  modGreedy     uint16 = ^(modLazy | modPossessive)
 )
 
 const (
   asmPath = iota; asmPathEle; asmPathEnd;
   asmGroup; asmGroupEnd; asmHook; asmHookEnd; asmSet; asmSetEnd;
-  asmBackref; asmMeta; asmRangeab; asmUTF8; asmPoint; asmSimple; asmEnd
+  asmBackref; asmMeta; asmRangeab; asmUTF8; asmPoint; asmSimple; asmEnd;
+  asmAhead; asmBehind; asmLookEnd; asmAnchor; asmClass
 )
 
 type reStruct struct {
   str                string
   reType             uint8
-  mods               uint8
+  mods               uint16
   loopsMin, loopsMax int
 }
 
 type catchInfo struct { init, end, id int }
 
+// matchInfo is a successful attempt of the scan loop, its hooks are the
+// catches from index catch up to the catch of the next match
+type matchInfo struct { init, end, catch int }
+
 type raptorASM struct {
   re    reStruct
   inst  uint8
   close int
+  id    int
+  width width     // bytes that a lookbehind can match
+  table runeTable // runes of a Unicode class ":p{name}"
 }
 
-type RE struct {
-  txt, re      string
-  compile      bool
+// frame is the continuation of a search: what remains to match once the path,
+// group or hook at index reaches its end
+type frame struct {
+  index int
+  loops int
+  init  int
+  catch int
+  next  int
+}
+
+// Regexp is a compiled expression, it is never modified after compilation and
+// can be shared between goroutines
+type Regexp struct {
+  re           string
+  asm          []raptorASM
+  mods         uint16
+  hooks        int
+  names        []string // name of each hook id, "" when unnamed
+  stepLimit    int      // steps of each search of its matchers, 0 is no limit
+  prefix       string   // literal that starts every match, "" when unknown
+  first        *byteSet // bytes that can start a match, nil when any
+  required     [][]string // a match contains one literal of each list
+  pike         []pikeInst // program of the Pike VM, nil to backtrack
+  dfa          bool       // the Pike program can run on the lazy DFA
+  memo         []int      // slot of each track in the memo, -1 when it is not memoized
+  memos        int
+  bounded      bool       // no backreference, the backtracking has a budget of fallbackSteps
+}
+
+// byteSet is a set of bytes, as the first bytes of the matches
+type byteSet [256]bool
+
+// Matcher holds the state of a search over one text: position, result and
+// catches, it runs the program of its Regexp
+type Matcher struct {
+  *Regexp
+
+  txt          string
+  bytes        []byte // input of MatchBytes, txt shares its memory
   result       int
 
   end          int
   pos          int
+  base         int  // offset of txt inside the stream of MatchReader
+  hitEnd       bool // the search looked at the end of txt
 
   catches      []catchInfo
   catchIndex   int
-  catchIdIndex int
 
-  asm          []raptorASM
-  mods         uint8
+  matches      []matchInfo
+
+  frames       []frame
+  depth        int             // nested calls of trekking
+  stack        []int
+
+  limit        int             // steps of each search, 0 is no limit
+  budget       int             // steps of the current search, 0 is no limit
+  steps        int
+  ctx          context.Context // context of MatchStringContext
+  err          error           // why the last search stopped before its end
+
+  threads      [2][]pikeThread // lists of the Pike VM
+  marks        []int           // position where each pike instruction was added
+  waits        []int           // the same for the threads inside a rune
+  events       []pikeEvent
+  spare        []pikeEvent     // the other arena of compactEvents
+  remap        []int           // new index of each kept event
+  opened       []int           // catch of each open hook while replaying events
+
+  states       *dfaCache // states of the lazy DFA
+  pending      bool      // count found matches that capture has not recorded
+  want         int       // n of the pending search
+
+  visited      []uint64 // states where the search failed, by memo slot and position
+  memoized     bool     // the search uses visited
+}
+
+type RE struct {
+  Matcher
 }
 
 func (r *RE) Compile( re string ) *RE {
   r.catchIndex = 1
-  r.compile    = false
+  r.Regexp     = newRegexp( re )
+  return r
+}
+
+func newRegexp( re string ) *Regexp {
+  r    := &Regexp{ re: re }
   if len(re) == 0 { return r }
 
   rexp := reStruct{ str: re, reType: asmPath }
-  r.re  = re
   r.asm = make( []raptorASM, 0, 32 )
 
   getMods( &rexp, &rexp )
@@ -66,16 +169,224 @@ func (r *RE) Compile( re string ) *RE {
   } else             { r.genTracks( &rexp  ) }
 
   r.asm = append( r.asm, raptorASM{ inst: asmEnd, close: len(r.asm) } )
-  r.compile = true
+  r.genPrefix()
+  r.genRequired()
+  r.genPike()
+  r.genDFA()
+  r.bounded = !r.hasBackref( 0, len( r.asm ) )
   return r
 }
 
+const maxRequired = 3 // lists of required literals checked before a search
+const maxRepeat   = 64 // copies of a repeated literal in a required literal
+
+// genRequired keeps the most selective lists of literals that every match
+// contains, a text without them is rejected before the search
+func (r *Regexp) genRequired(){
+  r.required = r.requiredLits( 0 )
+  sort.SliceStable( r.required, func( i, j int ) bool {
+    return shortest( r.required[i] ) > shortest( r.required[j] )
+  } )
+
+  if len( r.required ) > maxRequired { r.required = r.required[:maxRequired] }
+}
+
+func shortest( lits []string ) (n int) {
+  for i, lit := range lits {
+    if i == 0 || len( lit ) < n { n = len( lit ) }
+  }
+
+  return
+}
+
+// requiredLits returns the literals that every match of the tracks from index
+// to the end of its path, group or hook contains, as lists where one literal
+// must appear, the consecutive literal tracks are joined
+func (r *Regexp) requiredLits( index int ) (lits [][]string) {
+  run := ""
+  flush := func(){
+    if run != "" { lits = append( lits, []string{ run } ) }
+    run = ""
+  }
+
+  for ; ; index = r.asm[ index ].close + 1 {
+    rexp := &r.asm[ index ].re
+    switch r.asm[ index ].inst {
+    case asmEnd, asmPathEle, asmPathEnd, asmGroupEnd, asmHookEnd, asmLookEnd: flush(); return
+    case asmAhead, asmBehind, asmAnchor: continue
+    case asmPath:
+      flush()
+      if any := r.pathLits( index ); any != nil { lits = append( lits, any ) }
+      continue
+    case asmGroup, asmHook:
+      flush()
+      if rexp.loopsMin > 0 { lits = append( lits, r.requiredLits( index + 1 )... ) }
+      continue
+    }
+
+    text := trackText( &r.asm[ index ] )
+    if text == "" || rexp.loopsMin == 0 { flush(); continue }
+
+    loops := rexp.loopsMin
+    if loops > maxRepeat { loops = maxRepeat }
+
+    run += strings.Repeat( text, loops )
+    if loops != rexp.loopsMax { flush() }
+  }
+}
+
+// pathLits returns a literal required by each alternative of the path at
+// index, nil when an alternative requires none
+func (r *Regexp) pathLits( index int ) (any []string) {
+  for ele := index + 1; r.asm[ ele ].inst == asmPathEle; ele = r.asm[ ele ].close {
+    best := ""
+    for _, l := range r.requiredLits( ele + 1 ) {
+      if len( l ) == 1 && len( l[0] ) > len( best ) { best = l[0] }
+    }
+
+    if best == "" { return nil }
+    any = append( any, best )
+  }
+
+  return
+}
+
+// trackText returns the text that a literal track matches, "" when it is not
+// a literal or ignores the case
+func trackText( asm *raptorASM ) string {
+  if (asm.re.mods & modCommunism) > 0 { return "" }
+
+  switch asm.inst {
+  case asmSimple, asmUTF8: return asm.re.str
+  case asmMeta:
+    if !strnchr( "aAdDwWsSbB&", rune( asm.re.str[1] ) ) { return asm.re.str[1:2] }
+  }
+
+  return ""
+}
+
+// hasRequired reports if txt contains a literal of each required list
+func (r *Regexp) hasRequired( txt string ) bool {
+  for _, any := range r.required {
+    found := false
+    for _, lit := range any {
+      if found = strings.Contains( txt, lit ); found { break }
+    }
+
+    if !found { return false }
+  }
+
+  return true
+}
+
+// genPrefix finds the literal prefix or the first bytes of the matches, the
+// scan loop jumps to their positions, a set with a continuation byte is
+// useless because it can point to the middle of a rune
+func (r *Regexp) genPrefix(){
+  r.prefix = r.literalPrefix( 0 )
+  if len( r.prefix ) > 0 && (r.prefix[0] & 0xC0) == 0x80 { r.prefix = "" }
+
+  set, empty := r.firstBytes( 0 )
+  if empty { return }
+
+  for b := 0x80; b < 0xC0; b++ {
+    if set[b] { return }
+  }
+
+  r.first = &set
+}
+
+// literalPrefix returns the text that every match from index starts with
+func (r *Regexp) literalPrefix( index int ) string {
+  for ; ; index = r.asm[ index ].close + 1 {
+    switch r.asm[ index ].inst {
+    case asmAhead, asmBehind, asmAnchor: continue
+    case asmGroup, asmHook:
+      if r.asm[ index ].re.loopsMin == 0 { return "" }
+      return r.literalPrefix( index + 1 )
+    case asmSimple, asmUTF8:
+      if r.asm[ index ].re.loopsMin == 0 || (r.asm[ index ].re.mods & modCommunism) > 0 { return "" }
+      return r.asm[ index ].re.str
+    }
+
+    return ""
+  }
+}
+
+// firstBytes returns the bytes that can start a match of the tracks from index
+// to the end of its path, group or hook, and if they can match no text
+func (r *Regexp) firstBytes( index int ) (set byteSet, empty bool) {
+  for ; ; index = r.asm[ index ].close + 1 {
+    var t byteSet
+    tEmpty := false
+
+    switch r.asm[ index ].inst {
+    case asmEnd, asmPathEle, asmPathEnd, asmGroupEnd, asmHookEnd, asmLookEnd: return set, true
+    case asmAhead, asmBehind, asmAnchor: continue
+    case asmPath:
+      for ele := index + 1; r.asm[ ele ].inst == asmPathEle; ele = r.asm[ ele ].close {
+        e, eEmpty := r.firstBytes( ele + 1 )
+        t.add( &e )
+        tEmpty = tEmpty || eEmpty
+      }
+
+      if set.add( &t ); !tEmpty { return set, false }
+      continue
+    case asmGroup, asmHook: t, tEmpty = r.firstBytes( index + 1 )
+    case asmSimple, asmUTF8:
+      if len( r.asm[ index ].re.str ) == 0 { continue }
+
+      c, _ := decodeRune( r.asm[ index ].re.str )
+      t[ r.asm[ index ].re.str[0] ] = true
+      if (r.asm[ index ].re.mods & modCommunism) > 0 {
+        for f := simpleFold( c ); f != c; f = simpleFold( f ) { t[ string( f )[0] ] = true }
+      }
+    case asmSet  : t.addTable( r.asm[ index ].table )
+    case asmMeta : t.addTable( metaTable( r.asm[ index ].re.str[1], r.asm[ index ].re.mods ) )
+    case asmClass:
+      if r.asm[ index ].re.str[1] == 'P' { t.addTable( r.asm[ index ].table.negate() )
+      } else                             { t.addTable( r.asm[ index ].table ) }
+    default: // point and backref
+      for b := range t { t[b] = true }
+      tEmpty = r.asm[ index ].inst == asmBackref
+    }
+
+    set.add( &t )
+    if !tEmpty && r.asm[ index ].re.loopsMin > 0 { return set, false }
+  }
+}
+
+func (s *byteSet) add( t *byteSet ){
+  for b, ok := range t {
+    if ok { s[b] = true }
+  }
+}
+
+// addTable adds the first bytes of the UTF-8 of the runes of t, U+FFFD stands
+// for the invalid bytes
+func (s *byteSet) addTable( t runeTable ){
+  for _, r := range t {
+    if r.lo <= 0xFFFD && r.hi >= 0xFFFD {
+      for b := 0x80; b < 256; b++ { s[b] = true }
+    }
+
+    for c := r.lo; c <= r.hi && c < 128; c++ { s[c] = true }
+
+    if r.hi >= 128 {
+      lo := r.lo
+      if lo < 128 { lo = 128 }
+
+      for b := string( lo )[0]; b <= string( r.hi )[0]; b++ { s[b] = true }
+    }
+  }
+}
+
 func isPath( rexp *reStruct ) bool {
   if len(rexp.str) == 0 { return false }
 
   for i, deep := 0, 0; walkMeta( rexp.str[i:], &i ) < len( rexp.str ); i++ {
     switch rexp.str[ i ] {
-    case '(', '<': deep++
+    case '(', '<': deep++; i += lookAround( rexp.str[i:] )
     case ')', '>': deep--
     case '[': i += walkSet( rexp.str[i:] )
     case '|': if deep == 0 { return true }
@@ -85,29 +396,36 @@ func isPath( rexp *reStruct ) bool {
   return false
 }
 
-func (r *RE) genPaths( rexp reStruct ){
+func (r *Regexp) genPaths( rexp reStruct ){
   var track reStruct
   pathIndex := len( r.asm )
   r.asm = append( r.asm, raptorASM{ inst: asmPath, re: rexp } )
 
+  hooks, maxHooks := r.hooks, r.hooks
   for cutByType( &rexp, &track, asmPath ) {
     trackIndex := len( r.asm )
+    r.hooks     = hooks
     r.asm = append( r.asm, raptorASM{ inst: asmPathEle, re: track } )
     r.genTracks( &track )
     r.asm[trackIndex].close = len( r.asm )
+    if r.hooks > maxHooks { maxHooks = r.hooks }
   }
 
+  r.hooks = maxHooks
+
   r.asm[pathIndex].close = len( r.asm )
   r.asm = append( r.asm, raptorASM{ inst: asmPathEnd, close: len(r.asm) } )
 }
 
-func (r *RE) genTracks( rexp *reStruct ){
+func (r *Regexp) genTracks( rexp *reStruct ){
   var track reStruct
   for tracker( rexp, &track ) {
     trackIndex := len( r.asm )
     switch track.reType {
     case asmHook   :
-      r.asm = append( r.asm, raptorASM{ inst: asmHook, re: track } )
+      r.hooks++
+      r.setName( cutName( &track ), r.hooks )
+      r.asm = append( r.asm, raptorASM{ inst: asmHook, re: track, id: r.hooks } )
 
       if isPath( &track ) { r.genPaths ( track )
       } else              { r.genTracks( &track ) }
@@ -122,9 +440,20 @@ func (r *RE) genTracks( rexp *reStruct ){
 
       r.asm[trackIndex].close = len( r.asm )
       r.asm = append( r.asm, raptorASM{ inst: asmGroupEnd, close: len(r.asm) } )
+    case asmAhead, asmBehind:
+      r.asm = append( r.asm, raptorASM{ inst: track.reType, re: track } )
+
+      if isPath( &track ) { r.genPaths ( track )
+      } else              { r.genTracks( &track ) }
+
+      r.asm[trackIndex].close = len( r.asm )
+      r.asm = append( r.asm, raptorASM{ inst: asmLookEnd, close: len(r.asm) } )
+      r.asm[trackIndex].width = r.width( trackIndex + 1 )
     case asmPath   :
     case asmSet    : r.genSet( &track )
-    case asmBackref: r.asm = append( r.asm, raptorASM{ inst: asmBackref, close: trackIndex, re: track } )
+    case asmBackref: r.asm = append( r.asm, raptorASM{ inst: asmBackref, close: trackIndex, re: track, id: r.backrefId( track.str ) } )
+    case asmAnchor : r.asm = append( r.asm, raptorASM{ inst: asmAnchor , close: trackIndex, re: track } )
+    case asmClass  : r.asm = append( r.asm, raptorASM{ inst: asmClass  , close: trackIndex, re: track, table: unicodeClass( track.str ) } )
     case asmMeta   : r.asm = append( r.asm, raptorASM{ inst: asmMeta   , close: trackIndex, re: track } )
     case asmRangeab: r.asm = append( r.asm, raptorASM{ inst: asmRangeab, close: trackIndex, re: track } )
     case asmUTF8   : r.asm = append( r.asm, raptorASM{ inst: asmUTF8   , close: trackIndex, re: track } )
@@ -134,36 +463,168 @@ func (r *RE) genTracks( rexp *reStruct ){
   }
 }
 
-func (r *RE) genSet( rexp *reStruct ){
-  if len(rexp.str) == 0 { return }
+// cutName removes the "{name}" prefix of a hook and returns the name
+func cutName( track *reStruct ) string {
+  if len( track.str ) == 0 || track.str[0] != '{' { return "" }
 
-  if rexp.str[0] == '^' {
-    rexp.str   = rexp.str[1:]
-    rexp.mods |= modNegative
+  for i := 1; i < len( track.str ); i++ {
+    if track.str[i] == '}' {
+      name     := track.str[1:i]
+      track.str = track.str[i + 1:]
+      return name
+    }
   }
 
-  setIndex := len( r.asm )
-  r.asm = append( r.asm, raptorASM{ inst: asmSet, re: *rexp } )
+  return ""
+}
+
+func (r *Regexp) setName( name string, id int ){
+  if name == "" { return }
+
+  for len( r.names ) <= id { r.names = append( r.names, "" ) }
+  r.names[ id ] = name
+}
+
+// backrefId returns the hook id of a "@N" or "@{name}" backreference
+func (r *Regexp) backrefId( str string ) int {
+  if len( str ) > 1 && str[1] == '{' { return r.IdByName( str[2:len( str ) - 1] ) }
+
+  return aToi( str[1:] )
+}
+
+// genSet compiles the set to the table of its runes, the items, the operators
+// and the negation are solved here and matchSet only searches the table
+func (r *Regexp) genSet( rexp *reStruct ){
+  if len(rexp.str) == 0 { return }
+
+  if rexp.str[0] == '^' { rexp.mods |= modNegative }
+
+  r.asm = append( r.asm, raptorASM{ inst: asmSet, close: len(r.asm), re: *rexp, table: setTable( rexp.str, rexp.mods ) } )
+}
+
+// setTable returns the runes of the body of a set, with its "^" and the
+// operators "&&[set]" (intersection) and "--[set]" (subtraction) solved from
+// left to right
+func setTable( str string, mods uint16 ) (t runeTable) {
+  negative := len( str ) > 0 && str[0] == '^'
+  if negative { str = str[1:] }
+
+  op   := setOperator( str )
+  rexp := reStruct{ str: str[:op], mods: mods }
 
   var track reStruct
-  for trackerSet( rexp, &track ) {
-    switch track.reType {
-    case asmMeta   : r.asm = append( r.asm, raptorASM{ inst: asmMeta   , close: len(r.asm), re: track } )
-    case asmRangeab: r.asm = append( r.asm, raptorASM{ inst: asmRangeab, close: len(r.asm), re: track } )
-    case asmUTF8   : r.asm = append( r.asm, raptorASM{ inst: asmUTF8   , close: len(r.asm), re: track } )
-    default        : r.asm = append( r.asm, raptorASM{ inst: asmSimple , close: len(r.asm), re: track } )
+  for trackerSet( &rexp, &track ) { t = append( t, trackTable( &track )... ) }
+
+  t = t.merge()
+  for str = str[op:]; len( str ) > 0; str = str[setOperator( str ):] {
+    end    := 3 + walkSet( str[3:] )
+    nested := setTable( str[3:end], mods )
+    if str[0] == '&' { t = t.intersect( nested )
+    } else           { t = t.subtract ( nested ) }
+
+    if end >= len( str ) { break }
+    str = str[end + 1:]
+  }
+
+  if negative { return t.negate() }
+  return
+}
+
+// trackTable returns the runes of an item of a set, under "#*" the characters
+// and ranges include their other cases
+func trackTable( track *reStruct ) (t runeTable) {
+  switch track.reType {
+  case asmMeta : return metaTable( track.str[1], track.mods )
+  case asmClass:
+    if track.str[1] == 'P' { return unicodeClass( track.str ).negate() }
+    return append( t, unicodeClass( track.str )... )
+  case asmRangeab:
+    lo, n := setRune( track.str )
+    hi, _ := setRune( track.str[n + 1:] )
+    t = t.add( lo, hi )
+  default:
+    for _, c := range track.str { t = t.add( c, c ) }
+  }
+
+  if (track.mods & modCommunism) > 0 { return t.merge().fold() }
+  return
+}
+
+// metaTable returns the runes of the meta ":c" of a set, ASCII or Unicode
+// as matchMeta and matchMetaU
+func metaTable( c byte, mods uint16 ) (t runeTable) {
+  u := (mods & modUnicode) > 0
+
+  switch c {
+  case 'a', 'A':
+    if t = (runeTable{ { 'A', 'Z' }, { 'a', 'z' } }); u { t = tableLetter }
+  case 'd', 'D':
+    if t = (runeTable{ { '0', '9' } }); u { t = tableDigit }
+  case 'w', 'W':
+    if t = (runeTable{ { '0', '9' }, { 'A', 'Z' }, { 'a', 'z' } }); u {
+      t = append( append( runeTable{}, tableLetter... ), tableDigit... ).merge()
     }
+  case 's', 'S':
+    if t = (runeTable{ { '\t', '\r' }, { ' ', ' ' } }); u { t = tableSpace }
+  case 'b', 'B':
+    if t = (runeTable{ { '\t', '\t' }, { ' ', ' ' } }); u { t = tableBlank }
+  case '&': return t.add( 128, runeMax )
+  default : return t.add( rune( c ), rune( c ) )
   }
 
-  r.asm[ setIndex ].close = len( r.asm )
-  r.asm = append( r.asm, raptorASM{ inst: asmSetEnd, close: len(r.asm) } )
+  if c < 'a' { return t.negate() }
+  return append( runeTable{}, t... )
+}
+
+// width returns the range of bytes matched by the tracks from index to the end
+// of its path, group, hook or lookaround
+func (r *Regexp) width( index int ) (w width) {
+  for ; ; index = r.asm[ index ].close + 1 {
+    t := width{ 1, 1 }
+    switch r.asm[ index ].inst {
+    case asmEnd, asmPathEle, asmPathEnd, asmGroupEnd, asmHookEnd, asmLookEnd: return
+    case asmAhead, asmBehind, asmAnchor: continue
+    case asmPath:
+      t = width{ inf, 0 }
+      for ele := index + 1; r.asm[ ele ].inst == asmPathEle; ele = r.asm[ ele ].close {
+        e := r.width( ele + 1 )
+        if e.min < t.min { t.min = e.min }
+        if e.max > t.max { t.max = e.max }
+      }
+
+      w = w.add( t, 1, 1 )
+      continue
+    case asmGroup, asmHook  : t = r.width( index + 1 )
+    case asmSimple, asmUTF8 :
+      t = width{ len( r.asm[ index ].re.str ), len( r.asm[ index ].re.str ) }
+      if (r.asm[ index ].re.mods & modCommunism) > 0 {
+        n := 0
+        for range r.asm[ index ].re.str { n++ }
+        t = width{ n, n * utf8Max }
+      }
+    case asmBackref         : t = width{ 0, inf }
+    case asmPoint, asmSet, asmClass: t.max = utf8Max
+    case asmMeta            :
+      if strnchr( "ADWSB&", rune( r.asm[ index ].re.str[1] ) ) || (r.asm[ index ].re.mods & modUnicode) > 0 {
+        t.max = utf8Max
+      }
+    }
+
+    w = w.add( t, r.asm[ index ].re.loopsMin, r.asm[ index ].re.loopsMax )
+  }
 }
 
 func trackerSet( rexp, track *reStruct ) bool {
   if len( rexp.str ) == 0 { return false }
 
-  if rexp.str[0] > 127 {
+  if n := rangeLen( rexp.str ); n > 0 {
+    cutByLen( rexp, track, n, asmRangeab )
+  } else if rexp.str[0] > 127 {
     cutByLen( rexp, track, utf8meter( rexp.str ), asmUTF8 )
+  } else if n := classLen( rexp.str ); n > 0 {
+    cutByLen ( rexp, track, n, asmClass )
+  } else if n := setEscapeLen( rexp.str ); n > 0 {
+    cutEscape( rexp, track, n )
   } else if rexp.str[0] == ':' {
     cutByLen ( rexp, track, 2, asmMeta  )
   } else {
@@ -174,9 +635,8 @@ func trackerSet( rexp, track *reStruct ) bool {
         switch rexp.str[i] {
         case ':': cutByLen( rexp, track, i, asmSimple  ); goto setLM;
         case '-':
-          if i == 1 { cutByLen( rexp, track,     3, asmRangeab )
-          } else    { cutByLen( rexp, track, i - 1, asmSimple  ) }
-
+          if i > 1 { cutByLen( rexp, track, i - 1, asmSimple  )
+          } else   { cutByLen( rexp, track, i + 1, asmSimple  ) }
           goto setLM;
         }
       }
@@ -198,11 +658,17 @@ func tracker( rexp, track *reStruct ) bool {
     cutByLen( rexp, track, utf8meter( rexp.str ), asmUTF8 )
   } else {
     switch rexp.str[0] {
-    case ':': cutByLen ( rexp, track, 2,     asmMeta    )
+    case ':':
+      if isAnchor( rexp.str ) { cutByLen( rexp, track, 2, asmAnchor )
+      } else if n := classLen( rexp.str ); n > 0 {
+                                cutByLen( rexp, track, n, asmClass  )
+      } else if n := escapeLen( rexp.str ); n > 0 {
+                                cutEscape( rexp, track, n           )
+      } else                  { cutByLen( rexp, track, 2, asmMeta   ) }
     case '.': cutByLen ( rexp, track, 1,     asmPoint   )
     case '@': cutByLen ( rexp, track, 1 +
-            countCharDigits( rexp.str[1:] ), asmBackref )
-    case '(': cutByType( rexp, track,        asmGroup   )
+            backrefLen( rexp.str[1:] ),      asmBackref )
+    case '(': cutByType( rexp, track,        asmGroup   ); cutLook( track )
     case '<': cutByType( rexp, track,        asmHook    )
     case '[': cutByType( rexp, track,        asmSet     )
     default : cutSimple( rexp, track                    )
@@ -214,6 +680,143 @@ func tracker( rexp, track *reStruct ) bool {
   return true
 }
 
+// backrefLen returns the length of the id "N" or "{name}" of a backreference
+func backrefLen( str string ) int {
+  if len( str ) > 0 && str[0] == '{' {
+    for i := 1; i < len( str ); i++ {
+      if str[i] == '}' { return i + 1 }
+    }
+  }
+
+  return countCharDigits( str )
+}
+
+// isAnchor reports if str starts with the meta of a zero width anchor: ":i"
+// start of text, ":f" end of text, ":h" start of line, ":e" end of line, ":m"
+// word boundary and ":M" not word boundary
+func isAnchor( str string ) bool {
+  return len( str ) > 1 && str[0] == ':' && strnchr( "ifhemM", rune( str[1] ) )
+}
+
+// rangeLen returns the length of the range "a-z" at the start of str, the
+// limits are runes of any width, or 0 when str does not start with a range
+func rangeLen( str string ) int {
+  _, n := setRune( str )
+  if n == 0 || n + 1 >= len( str ) || str[n] != '-' { return 0 }
+
+  _, m := setRune( str[n + 1:] )
+  if m == 0 { return 0 }
+
+  return n + 1 + m
+}
+
+// setRune returns the rune at the start of str and its length, a character or
+// an escape, or a length 0 for the other metas
+func setRune( str string ) (rune, int) {
+  if len( str ) == 0 { return 0, 0 }
+  if str[0] != ':' { return decodeRune( str ) }
+
+  n := setEscapeLen( str )
+  if n == 0 { return 0, 0 }
+
+  c, _ := decodeRune( unescape( str[:n] ) )
+  return c, n
+}
+
+// escapeLen returns the length of the escape at the start of str, ":n", ":t",
+// ":r", the byte ":xHH" or the code point ":u{H...}", or 0
+func escapeLen( str string ) int {
+  if len( str ) < 2 || str[0] != ':' { return 0 }
+
+  switch str[1] {
+  case 'n', 't', 'r': return 2
+  case 'x':
+    if len( str ) >= 4 && isXDigit( rune( str[2] ) ) && isXDigit( rune( str[3] ) ) { return 4 }
+  case 'u':
+    if len( str ) < 5 || str[2] != '{' { return 0 }
+
+    for i := 3; i < len( str ) && i < 3 + 7; i++ {
+      if str[i] == '}' {
+        if i > 3 && hexToi( str[3:i] ) <= runeMax { return i + 1 }
+        return 0
+      }
+
+      if !isXDigit( rune( str[i] ) ) { return 0 }
+    }
+  }
+
+  return 0
+}
+
+// setEscapeLen is escapeLen inside a set, the items of a set are runes so a
+// byte ":xHH" of 0x80 or more is not an escape there
+func setEscapeLen( str string ) int {
+  n := escapeLen( str )
+  if n == 4 && str[1] == 'x' && hexToi( str[2:4] ) >= 0x80 { return 0 }
+
+  return n
+}
+
+// unescape returns the text of an escape of escapeLen
+func unescape( str string ) string {
+  switch str[1] {
+  case 'n': return "\n"
+  case 't': return "\t"
+  case 'r': return "\r"
+  case 'x': return string( []byte{ byte( hexToi( str[2:4] ) ) } )
+  }
+
+  return string( rune( hexToi( str[3:len( str ) - 1] ) ) )
+}
+
+// cutEscape cuts the escape of length n as a track of its text
+func cutEscape( rexp, track *reStruct, n int ){
+  cutByLen( rexp, track, n, asmSimple )
+  if track.str = unescape( track.str ); track.str[0] > 127 { track.reType = asmUTF8 }
+}
+
+// classLen returns the length of the Unicode class ":p{name}" or ":P{name}"
+// at the start of str, or 0
+func classLen( str string ) int {
+  if len( str ) < 4 || str[0] != ':' || (str[1] != 'p' && str[1] != 'P') || str[2] != '{' { return 0 }
+
+  for i := 3; i < len( str ); i++ {
+    if str[i] == '}' { return i + 1 }
+  }
+
+  return 0
+}
+
+// unicodeClass returns the table of the class ":p{name}"
+func unicodeClass( str string ) runeTable {
+  return unicodeClasses[ str[3:len( str ) - 1] ]
+}
+
+// lookAround returns the length of the lookahead "?=", "?!" or lookbehind
+// "?<=", "?<!" prefix of a group
+func lookAround( str string ) int {
+  if len( str ) < 3 || str[0] != '(' || str[1] != '?' { return 0 }
+
+  switch str[2] {
+  case '=', '!': return 2
+  case '<':
+    if len( str ) > 3 && (str[3] == '=' || str[3] == '!') { return 3 }
+  }
+
+  return 0
+}
+
+func cutLook( track *reStruct ){
+  look := lookAround( "(" + track.str )
+  if look == 0 { return }
+
+  if look == 2 { track.reType = asmAhead
+  } else       { track.reType = asmBehind }
+
+  if track.str[look - 1] == '!' { track.mods |= modNegative }
+  track.str = track.str[look:]
+}
+
 func cutSimple( rexp, track *reStruct ){
   for i, c := range rexp.str {
     if c > 127 {
@@ -236,6 +839,7 @@ func cutSimple( rexp, track *reStruct ){
 func cutByLen( rexp, track *reStruct, length int, reType uint8 ){
   *track       = *rexp
   track.str    = rexp.str[:length]
+  track.mods  &= modPositive
   rexp.str     = rexp.str[length:]
   track.reType = reType;
 }
@@ -245,9 +849,10 @@ func cutByType( rexp, track *reStruct, reType uint8 ) bool {
 
   *track       = *rexp
   track.reType = reType
+  track.mods  &= modPositive
   for i , deep, cut := 0, 0, false; walkMeta( rexp.str[i:], &i ) < len( rexp.str ); i++ {
     switch rexp.str[ i ] {
-    case '(', '<': deep++
+    case '(', '<': deep++; i += lookAround( rexp.str[i:] )
     case ')', '>': deep--
     case '[': i += walkSet( rexp.str[i:] )
     }
@@ -273,11 +878,29 @@ func cutByType( rexp, track *reStruct, reType uint8 ) bool {
 func walkSet( str string ) int {
   for i := 0; walkMeta( str[i:], &i ) < len( str ); i++ {
     if str[i] == ']' { return i }
+    if isSetOperator( str[i:] ) {
+      if i += 3 + walkSet( str[i + 3:] ); i >= len( str ) { break }
+    }
   }
 
   return len(str);
 }
 
+// isSetOperator reports if str starts with "&&[" or "--["
+func isSetOperator( str string ) bool {
+  return len( str ) > 2 && str[2] == '[' && (str[:2] == "&&" || str[:2] == "--")
+}
+
+// setOperator returns the index of the first operator of the body of a set,
+// or its length when there is none
+func setOperator( str string ) int {
+  for i := 0; walkMeta( str[i:], &i ) < len( str ); i++ {
+    if isSetOperator( str[i:] ) { return i }
+  }
+
+  return len( str )
+}
+
 func walkMeta( str string, n *int ) int {
   for i := 0; i < len( str ); i += 2 {
     if str[i] != ':' { *n += i; return *n }
@@ -297,6 +920,8 @@ func getMods( rexp, track *reStruct ){
       case '~': track.mods |= modFwrByChar
       case '*': track.mods |= modCommunism
       case '/': track.mods &= modCapitalism
+      case '!': track.mods |= modMultiline
+      case '&': track.mods |= modUnicode
       default : rexp.str    = rexp.str[i+1:]; return
       }
     }
@@ -308,6 +933,7 @@ func getMods( rexp, track *reStruct ){
 func getLoops( rexp, track *reStruct ){
   pos := 0;
   track.loopsMin, track.loopsMax = 1, 1
+  track.mods &= modGreedy
 
   if len( rexp.str ) > 0 {
     switch rexp.str[0] {
@@ -331,6 +957,13 @@ func getLoops( rexp, track *reStruct ){
       }
     }
 
+    if pos > 0 && pos < len( rexp.str ) {
+      switch rexp.str[pos] {
+      case '+': pos++; track.mods |= modPossessive
+      case '?': pos++; track.mods |= modLazy
+      }
+    }
+
     rexp.str = rexp.str[pos:]
   }
 }
@@ -345,137 +978,464 @@ func (r *RE) Match( txt, re string ) int {
   return r.Compile( re ).MatchString( txt )
 }
 
-func (r *RE) FindString( txt string ) bool {
+func (r *Matcher) FindString( txt string ) bool {
   return r.MatchString( txt ) > 0
 }
 
-func (r *RE) MatchString( txt string ) int {
+func (r *Matcher) MatchString( txt string ) int {
+  return r.count( txt, -1 )
+}
+
+// MatchStringContext is MatchString that stops when ctx is done, it returns
+// the matches found before stopping and ctx.Err() or ErrStepLimit
+func (r *Matcher) MatchStringContext( ctx context.Context, txt string ) (int, error) {
+  r.ctx = ctx
+  defer func(){ r.ctx = nil }()
+
+  if err := ctx.Err(); err != nil { r.err = err; return 0, err }
+
+  result := r.count( txt, -1 )
+  return result, r.err
+}
+
+// SetStepLimit limits the steps of the engine in each search to n, 0 is no
+// limit, a search that runs out stops with the error ErrStepLimit
+func (r *Matcher) SetStepLimit( n int ){ r.limit = n }
+
+// Err returns ErrStepLimit, ErrDepthLimit or the error of the context when
+// the last search stopped before its end, or the read error that ended the
+// stream of MatchReader, nil otherwise
+func (r *Matcher) Err() error { return r.err }
+
+// step counts one step of the engine, it reports false when the search must
+// stop by the step limit or the context
+func (r *Matcher) step() bool {
+  if r.err != nil { return false }
+
+  r.steps++
+  if r.budget > 0 && r.steps > r.budget { r.err = ErrStepLimit; return false }
+
+  if r.ctx != nil && r.steps % ctxSteps == 0 {
+    if err := r.ctx.Err(); err != nil { r.err = err; return false }
+  }
+
+  return true
+}
+
+func (r *Matcher) FindBytes( b []byte ) bool {
+  return r.MatchBytes( b ) > 0
+}
+
+// MatchBytes searches b without copying it, the catches are subslices of b so
+// it must not be modified while they are in use
+func (r *Matcher) MatchBytes( b []byte ) int {
+  result := r.count( bytesToString( b ), -1 )
+  r.bytes = b
+  return result
+}
+
+// reset clears the state of the last search, it reports false when txt can
+// not match
+func (r *Matcher) reset( txt string ) bool {
   r.end        = len(txt)
   r.txt        = txt
+  r.base       = 0
+  r.bytes      = nil
   r.result     = 0
-  r.catches    = make( []catchInfo, 32 )
   r.catchIndex = 1
-  if r.end == 0  || !r.compile { return 0 }
+  r.matches    = r.matches[:0]
+  r.steps      = 0
+  r.budget     = r.limit
+  r.err        = nil
+  r.pending    = false
+  r.memoized   = false
+  if r.catches == nil { r.catches = make( []catchInfo, 32 ) }
+
+  return r.end > 0 && r.Regexp != nil && len(r.asm) > 0 && r.hasRequired( txt )
+}
+
+// scan tries the expression along txt and records each match, it stops after
+// n matches when n >= 0
+func (r *Matcher) scan( txt string, n int ) int {
+  if !r.reset( txt ) { return 0 }
+
+  if r.pike != nil { return r.pikeScan( txt, n ) }
+  if r.budget == 0 && r.bounded { r.budget = fallbackSteps * len( r.asm ) * (r.end + 1) }
+
+  r.memoized = r.memo != nil && r.resetMemo()
 
-  loops := r.end
-  if (r.mods & modAlpha) > 0 { loops = 1 }
+  loops, lines := r.end, (r.mods & modMultiline) > 0
+  if (r.mods & modAlpha) > 0 && !lines { loops = 1 }
 
   for forward, i, ocindex := 0, 0, 0; i < loops; i += forward {
-    forward, r.catchIdIndex, r.pos = utf8meter( txt[i:] ), 1, i
+    if loops > 1 {
+      if i = r.candidate( txt, i ); i >= loops { break }
+    }
+
+    forward, r.pos = utf8meter( txt[i:] ), i
+    if r.skipLine( i ) {
+      forward = nextLine( txt[i:] )
+      continue
+    }
+
     ocindex = r.catchIndex
 
-    if r.trekking( 0 ) {
-      if (r.mods & modOmega) > 0 {
-        if r.pos == r.end                                 { r.result = 1; return 1
-        } else { r.catchIndex = 1 }
-      } else if (r.mods & modLonley   ) > 0               { r.result = 1; return 1
+    ok := r.trekking( 0, -1 )
+    if r.err != nil { r.catchIndex = ocindex; return r.result }
+
+    if ok {
+      r.matches = append( r.matches, matchInfo{ i, r.pos, ocindex } )
+      if (r.mods & modLonley) > 0 || ((r.mods & modOmega) > 0 && !lines) { r.result = 1; return 1
       } else if (r.mods & modFwrByChar) > 0 || r.pos == i { r.result++
       } else {   forward = r.pos - i;                       r.result++; }
+
+      if r.result == n { return r.result }
     } else { r.catchIndex = ocindex }
   }
 
   return r.result
 }
 
-func (r *RE) trekking( index int ) (result bool) {
-  for ; r.asm[ index ].inst != asmEnd; index = r.asm[ index ].close + 1 {
+// candidate returns the first position from i where a match can start, by
+// the literal prefix or the first bytes, len( txt ) when there is none
+func (r *Regexp) candidate( txt string, i int ) int {
+  if len( r.prefix ) > 0 {
+    if j := strings.Index( txt[i:], r.prefix ); j >= 0 { return i + j }
+    return len( txt )
+  }
+
+  if r.first == nil { return i }
+
+  for ; i < len( txt ) && !r.first[ txt[i] ]; i++ {}
+  return i
+}
+
+// trek matches the track at index and everything after it, k is the
+// continuation to resume when a path, group or hook reaches its end
+func (r *Matcher) trek( index, k int ) bool {
+  for {
+    if !r.step() { return false }
+
     switch r.asm[ index ].inst {
-    case asmPathEnd, asmPathEle, asmGroupEnd, asmHookEnd, asmSetEnd: return true
-    case asmHook : result = r.catcher  ( index )
-    case asmGroup: result = r.loopGroup( index )
-    case asmPath : result = r.walker   ( index )
-    default      : result = r.looper   ( index )
+    case asmEnd  : return r.atOmega()
+
+    case asmPathEnd, asmPathEle, asmGroupEnd, asmHookEnd, asmLookEnd: return r.resume( k )
+    case asmHook : return r.catcher  ( index, k )
+    case asmGroup: return r.loopGroup( index, -1, 0, k )
+    case asmPath : return r.walker   ( index, k )
+    case asmAhead, asmBehind:
+      if !r.look( index ) { return false }
+      index = r.asm[ index ].close + 1
+      continue
+    case asmAnchor:
+      if r.asm[ index ].re.loopsMin > 0 && !r.anchor( index ) { return false }
+      index = r.asm[ index ].close + 1
+      continue
     }
 
-    if !result { return false }
+    if r.asm[ index ].re.loopsMin != r.asm[ index ].re.loopsMax {
+      return r.looper( index, k )
+    }
+
+    for loops, forward := 0, 0; loops < r.asm[ index ].re.loopsMin; loops++ {
+      if r.atEnd() || !r.match( index, r.txt[r.pos:], &forward ) { return false }
+      r.pos += forward
+    }
+
+    index = r.asm[ index ].close + 1
   }
+}
 
-  return true
+func (r *Matcher) pushFrame( f frame ) int {
+  r.frames = append( r.frames, f )
+  return len( r.frames ) - 1
+}
+
+func (r *Matcher) resume( k int ) bool {
+  if k < 0 { return true }
+
+  f := r.frames[ k ]
+  switch r.asm[ f.index ].inst {
+  case asmPath  : return r.trekking( r.asm[ f.index ].close + 1, f.next )
+  case asmBehind: return r.pos == f.init
+  }
+
+  if r.pos == f.init { return r.exitGroup( f.index, f.catch, f.next ) }
+
+  return r.loopGroup( f.index, f.catch, f.loops, f.next )
 }
 
-func (r *RE) catcher( index int ) bool {
+func (r *Matcher) catcher( index, k int ) bool {
   i := r.catchIndex
   if r.catchIndex < len(r.catches) {
-    r.catches[ i ] = catchInfo{ r.pos, r.pos, r.catchIdIndex }
+    r.catches[ i ] = catchInfo{ r.pos, r.pos, r.asm[ index ].id }
   } else {
-    r.catches = append( r.catches, catchInfo{ r.pos, r.pos, r.catchIdIndex } )
+    r.catches = append( r.catches, catchInfo{ r.pos, r.pos, r.asm[ index ].id } )
   }
 
   r.catchIndex++
-  r.catchIdIndex++
 
-  if !r.loopGroup( index ) { return false }
-
-  r.catches[ i ].end = r.pos
-  return true
+  return r.loopGroup( index, i, 0, k )
 }
 
-func (r *RE) walker( index int ) bool {
-  index++
-  for oPos, oCatchIndex, oCatchIdIndex := r.pos, r.catchIndex, r.catchIdIndex;
+func (r *Matcher) walker( index, k int ) bool {
+  path := index
+  for index, oPos, oCatchIndex := index + 1, r.pos, r.catchIndex;
       r.asm[ index ].inst == asmPathEle
-      index, r.pos, r.catchIndex, r.catchIdIndex = r.asm[ index ].close, oPos, oCatchIndex, oCatchIdIndex {
-    if r.trekking( index + 1 ) { return true }
+      index, r.pos, r.catchIndex = r.asm[ index ].close, oPos, oCatchIndex {
+    f  := r.pushFrame( frame{ index: path, next: k } )
+    ok := r.trekking( index + 1, f )
+    r.frames = r.frames[:f]
+
+    if ok { return true }
   }
 
   return false
 }
 
-func (r *RE) looper( index int ) bool {
-  loops := 0
-  for forward := 0; loops < r.asm[ index ].re.loopsMax && r.pos < r.end &&  r.match( index, r.txt[r.pos:], &forward ); {
+// looper repeats a simple track as much as it can and gives back one
+// iteration at a time until the rest of the expression matches
+func (r *Matcher) looper( index, k int ) bool {
+  rexp, next := &r.asm[ index ].re, r.asm[ index ].close + 1
+  if (rexp.mods & modLazy) > 0 { return r.lazyLooper( index, k ) }
+
+  base, oCatchIndex, loops := len( r.stack ), r.catchIndex, 0
+
+  for forward := 0; loops < rexp.loopsMax && !r.atEnd() && r.step() && r.match( index, r.txt[r.pos:], &forward ); loops++ {
+    r.stack = append( r.stack, r.pos )
+    r.pos  += forward
+  }
+
+  if (rexp.mods & modPossessive) > 0 {
+    r.stack = r.stack[:base]
+    return loops >= rexp.loopsMin && r.trekking( next, k )
+  }
+
+  for ; loops >= rexp.loopsMin; loops-- {
+    if r.trekking( next, k ) {
+      r.stack = r.stack[:base]
+      return true
+    }
+
+    if loops == 0 { break }
+    r.pos, r.catchIndex = r.stack[ base + loops - 1 ], oCatchIndex
+  }
+
+  r.stack = r.stack[:base]
+  return false
+}
+
+// lazyLooper takes the minimum of iterations and adds one at a time until the
+// rest of the expression matches
+func (r *Matcher) lazyLooper( index, k int ) bool {
+  rexp, next := &r.asm[ index ].re, r.asm[ index ].close + 1
+  oCatchIndex := r.catchIndex
+
+  for loops, forward := 0, 0; ; loops++ {
+    if loops >= rexp.loopsMin {
+      oPos := r.pos
+      if r.trekking( next, k ) { return true }
+      r.pos, r.catchIndex = oPos, oCatchIndex
+    }
+
+    if loops >= rexp.loopsMax || r.atEnd() || !r.step() || !r.match( index, r.txt[r.pos:], &forward ) {
+      return false
+    }
+
     r.pos += forward
-    loops++;
   }
+}
 
-  if loops < r.asm[ index ].re.loopsMin { return false }
-  return true
+// loopGroup runs one more iteration of the group or hook at index, and when
+// the rest of the expression fails after it, tries to leave the group instead
+// (the lazy loop tries to leave first)
+func (r *Matcher) loopGroup( index, catch, loops, k int ) bool {
+  rexp := &r.asm[ index ].re
+  if (rexp.mods & modPossessive) > 0 { return r.loopAtomic( index, catch, k ) }
+
+  if (rexp.mods & modLazy) > 0 && loops >= rexp.loopsMin {
+    oPos, oCatchIndex := r.pos, r.catchIndex
+    if r.exitGroup( index, catch, k ) { return true }
+    r.pos, r.catchIndex = oPos, oCatchIndex
+
+    return loops < rexp.loopsMax && r.iterate( index, catch, loops, k )
+  }
+
+  if loops < rexp.loopsMax && r.iterate( index, catch, loops, k ) { return true }
+
+  if loops < rexp.loopsMin { return false }
+  return r.exitGroup( index, catch, k )
+}
+
+func (r *Matcher) iterate( index, catch, loops, k int ) bool {
+  oPos, oCatchIndex := r.pos, r.catchIndex
+  f  := r.pushFrame( frame{ index: index, loops: loops + 1, init: r.pos, catch: catch, next: k } )
+  ok := r.trekking( index + 1, f )
+  r.frames = r.frames[:f]
+
+  if !ok { r.pos, r.catchIndex = oPos, oCatchIndex }
+  return ok
 }
 
-func (r *RE) loopGroup( index int ) bool {
+// loopAtomic is the possessive loop, every iteration is matched on its own and
+// the group never gives back what it consumed
+func (r *Matcher) loopAtomic( index, catch, k int ) bool {
   loops := 0
-  for loops < r.asm[ index ].re.loopsMax && r.trekking( index + 1 ) {
-    loops++;
+  for oPos, oCatchIndex := r.pos, r.catchIndex; loops < r.asm[ index ].re.loopsMax; loops++ {
+    if !r.trekking( index + 1, -1 ) {
+      r.pos, r.catchIndex = oPos, oCatchIndex
+      break
+    }
+
+    if r.pos == oPos { loops = r.asm[ index ].re.loopsMax; break }
+    oPos, oCatchIndex = r.pos, r.catchIndex
   }
 
   if loops < r.asm[ index ].re.loopsMin { return false }
+  return r.exitGroup( index, catch, k )
+}
+
+// look runs the lookahead or lookbehind at index without moving the position,
+// the catches of the assertion only survive when it is positive and matches
+func (r *Matcher) look( index int ) (found bool) {
+  oPos, oCatchIndex := r.pos, r.catchIndex
+
+  if r.asm[ index ].inst == asmAhead {
+    found = r.trekking( index + 1, -1 )
+  } else {
+    f := r.pushFrame( frame{ index: index, init: oPos, next: -1 } )
+    for init := oPos - r.asm[ index ].width.min; !found && init >= 0 && init >= oPos - r.asm[ index ].width.max; init-- {
+      if init < r.end && (r.txt[init] & 0xC0) == 0x80 { continue }
+
+      r.pos, r.catchIndex = init, oCatchIndex
+      found = r.trekking( index + 1, f )
+    }
+    r.frames = r.frames[:f]
+  }
+
+  r.pos = oPos
+  if !found || (r.asm[ index ].re.mods & modNegative) > 0 {
+    r.catchIndex = oCatchIndex
+    return !found && (r.asm[ index ].re.mods & modNegative) > 0
+  }
+
   return true
 }
 
-func (r *RE) match( index int, txt string, forward *int ) bool {
+// anchor tests the position against the anchor at index, it never consumes
+// text
+func (r *Matcher) anchor( index int ) bool {
+  switch r.asm[ index ].re.str[1] {
+  case 'i': return r.base + r.pos == 0
+  case 'f': return r.atEnd()
+  case 'h': return r.base + r.pos == 0 || r.txt[r.pos - 1] == '\n'
+  case 'e': return r.atEnd()           || r.txt[r.pos]     == '\n'
+  case 'm': return r.isWordBoundary( index )
+  case 'M': return !r.isWordBoundary( index )
+  }
+
+  return false
+}
+
+func (r *Matcher) isWordBoundary( index int ) bool {
+  if (r.asm[ index ].re.mods & modUnicode) > 0 {
+    before := r.base + r.pos > 0 && isAlnumU( lastRune( r.txt[:r.pos] ) )
+    after  := !r.atEnd()
+    if after { c, _ := decodeRune( r.txt[r.pos:] ); after = isAlnumU( c ) }
+    return before != after
+  }
+
+  before := r.base + r.pos > 0 && isAlnum( rune( r.txt[r.pos - 1] ) )
+  after  := !r.atEnd()         && isAlnum( rune( r.txt[r.pos]     ) )
+  return before != after
+}
+
+// atOmega reports if a match can end at the position, "#$" needs the end of
+// the text or, under "#!", of a line
+func (r *Matcher) atOmega() bool {
+  if (r.mods & modOmega) == 0 || r.atEnd() { return true }
+
+  return (r.mods & modMultiline) > 0 && r.txt[r.pos] == '\n'
+}
+
+// atEnd reports if the position is at the end of the text, and remembers that
+// the search needed to look there
+func (r *Matcher) atEnd() bool {
+  if r.pos < r.end { return false }
+
+  r.hitEnd = true
+  return true
+}
+
+// skipLine reports if the scan loop must not try at i, under "#^!" only the
+// starts of line are tried
+func (r *Matcher) skipLine( i int ) bool {
+  return (r.mods & (modAlpha | modMultiline)) == (modAlpha | modMultiline) &&
+    r.base + i > 0 && r.txt[i - 1] != '\n'
+}
+
+// exitGroup leaves the group or hook at index, the end of its catch is given
+// back when the rest of the expression fails, a backreference of the open
+// hook must not read it
+func (r *Matcher) exitGroup( index, catch, k int ) bool {
+  if catch <= 0 { return r.trekking( r.asm[ index ].close + 1, k ) }
+
+  end := r.catches[ catch ].end
+  r.catches[ catch ].end = r.pos
+  if r.trekking( r.asm[ index ].close + 1, k ) { return true }
+
+  r.catches[ catch ].end = end
+  return false
+}
+
+func (r *Matcher) match( index int, txt string, forward *int ) bool {
   switch r.asm[ index ].inst {
   case asmPoint  : *forward = utf8meter( txt );  return true
   case asmSet    : return r.matchSet    ( index, txt, forward )
-  case asmBackref: return r.matchBackRef( &r.asm[ index ].re, txt, forward )
+  case asmBackref: return r.matchBackRef( index, txt, forward )
   case asmRangeab: return matchRange    ( &r.asm[ index ].re, txt, forward )
   case asmMeta   : return matchMeta     ( &r.asm[ index ].re, txt, forward )
-  default        : return matchText     ( &r.asm[ index ].re, txt, forward )
+  case asmClass  :
+    c, size := decodeRune( txt )
+    *forward = size
+    return r.asm[ index ].table.has( c ) == (r.asm[ index ].re.str[1] == 'p')
+  default        :
+    if matchText( &r.asm[ index ].re, txt, forward ) { return true }
+
+    if *forward > len( txt ) { r.hitEnd = true }
+    return false
   }
 }
 
-func matchText( rexp *reStruct, txt string, forward *int ) bool {
+// matchText compares the text of the track with the start of txt, forward is
+// beyond txt when txt is too short
+func matchText( rexp *reStruct, txt string, forward *int ) (ok bool) {
+  if (rexp.mods & modCommunism) > 0 {
+    *forward, ok = strEqlCommunist( rexp.str, txt )
+    return
+  }
+
   *forward = len(rexp.str)
 
   if len(txt) < *forward { return false }
 
-  if (rexp.mods & modCommunism) > 0 {
-    return strnEqlCommunist( txt, rexp.str, *forward )
-  }
-
   return txt[:*forward] == rexp.str
 }
 
 func matchRange( rexp *reStruct, txt string, forward *int ) bool {
-  *forward = 1
-  if (rexp.mods & modCommunism) > 0 {
-    chr := toLower( rune(txt[0]) )
-    return chr >= toLower( rune(rexp.str[ 0 ]) ) && chr <= toLower( rune(rexp.str[ 2 ]) )
-  }
+  lo, n   := setRune( rexp.str )
+  hi, _   := setRune( rexp.str[n + 1:] )
+  c, size := decodeRune( txt )
+  *forward = size
+
+  if (rexp.mods & modCommunism) > 0 { return inRangeFold( c, lo, hi ) }
 
-  return txt[0] >= rexp.str[ 0 ] && txt[0] <= rexp.str[ 2 ];
+  return c >= lo && c <= hi
 }
 
 func matchMeta( rexp *reStruct, txt string, forward *int ) bool {
+  if (rexp.mods & modUnicode) > 0 { return matchMetaU( rexp, txt, forward ) }
+
   var f func( r rune ) bool
   *forward = 1
 
@@ -501,48 +1461,52 @@ func matchMeta( rexp *reStruct, txt string, forward *int ) bool {
   return true
 }
 
-func (r *RE) matchSet( index int, txt string, forward *int ) (result bool) {
-  *forward = 1
-  reverse := (r.asm[ index ].re.mods & modNegative) > 0
-
-  for index++; !result && r.asm[ index ].inst != asmSetEnd; index++ {
-    switch r.asm[ index ].inst {
-    case asmRangeab, asmUTF8, asmMeta:
-      result = r.match( index, txt, forward )
-    default:
-      if (r.asm[ index ].re.mods & modCommunism)  > 0 {
-        result = findRuneCommunist( r.asm[ index ].re.str, rune( txt[ 0 ] ) )
-      } else {
-        result = strnchr( r.asm[ index ].re.str, rune( txt[ 0 ] ) )
-      }
-    }
+// matchMetaU is matchMeta over the Unicode classes, the metas decode the whole
+// rune
+func matchMetaU( rexp *reStruct, txt string, forward *int ) bool {
+  var f func( r rune ) bool
+  c, size := decodeRune( txt )
+  positive := rexp.str[1] >= 'a'
 
-    if result {
-      if reverse { return false }
-      return true
-    }
+  switch rexp.str[1] {
+  case 'a', 'A': f = isLetterU
+  case 'd', 'D': f = isDigitU
+  case 'w', 'W': f = isAlnumU
+  case 's', 'S': f = isSpaceU
+  case 'b', 'B': f = isBlankU
+  case '&'     : positive, f = true, func( c rune ) bool { return c >= 128 }
+  default      : *forward = 1; return txt[0] == rexp.str[1]
   }
 
-  if reverse {
-    *forward = utf8meter( txt )
-    return true
-  }
+  *forward = size
+  return f( c ) == positive
+}
 
-  return false
+func (r *Matcher) matchSet( index int, txt string, forward *int ) bool {
+  c, size := decodeRune( txt )
+  *forward = size
+  return r.asm[ index ].table.has( c )
 }
 
-func (r *RE) matchBackRef( rexp *reStruct, txt string, forward *int ) bool {
-  backRefId    := aToi( rexp.str[1:] )
-  backRefIndex := r.lastIdCatch( backRefId )
+func (r *Matcher) matchBackRef( index int, txt string, forward *int ) bool {
+  backRefIndex := r.lastIdCatch( r.asm[ index ].id )
   strCatch     := r.GetCatch( backRefIndex )
+
+  if (r.asm[ index ].re.mods & modCommunism) > 0 && strCatch != "" {
+    n, ok := strEqlCommunist( strCatch, txt )
+    if *forward = n; n > len( txt ) { r.hitEnd = true }
+    return ok
+  }
+
   *forward      = len(strCatch)
 
+  if len( txt ) < *forward { r.hitEnd = true }
   if strCatch == "" || len( txt ) < *forward || strCatch != txt[:*forward] { return false }
 
   return true
 }
 
-func (r *RE) lastIdCatch( id int ) int {
+func (r *Matcher) lastIdCatch( id int ) int {
   for index := r.catchIndex - 1; index > 0; index-- {
     if r.catches[ index ].id == id { return index }
   }
@@ -550,26 +1514,88 @@ func (r *RE) lastIdCatch( id int ) int {
   return len(r.catches);
 }
 
-func (r *RE) Result  () int { return r.result }
+func (r *Matcher) firstIdCatch( id int ) int {
+  r.capture()
+  for index := 1; index < r.catchIndex; index++ {
+    if r.catches[ index ].id == id { return index }
+  }
+
+  return r.catchIndex
+}
 
-func (r *RE) TotCatch() int { return r.catchIndex - 1 }
+func (r *Matcher) Result  () int { return r.result }
 
-func (r *RE) GetCatch( index int ) string {
-  if index < 1 || index >= r.catchIndex { return "" }
-  return r.txt[ r.catches[index].init : r.catches[index].end ]
+func (r *Matcher) TotCatch() int { r.capture(); return r.catchIndex - 1 }
+
+// MatchCount returns the number of matches recorded by the last search
+func (r *Matcher) MatchCount() int { r.capture(); return len( r.matches ) }
+
+// MatchSpan returns the start and end positions of the match n (1 to
+// MatchCount), or -1 -1 for an incorrect n
+func (r *Matcher) MatchSpan( n int ) (int, int) {
+  r.capture()
+  if n < 1 || n > len( r.matches ) { return -1, -1 }
+  return r.matches[n - 1].init, r.matches[n - 1].end
 }
 
-func (r *RE) GpsCatch( index int ) int {
-  if index < 1 || index >= r.catchIndex { return 0 }
-  return r.catches[index].init
+// span returns the positions of the catch index, the catch 0 is the whole
+// first match
+func (r *Matcher) span( index int ) (init, end int, ok bool) {
+  r.capture()
+  if index == 0 && len( r.matches ) > 0 { return r.matches[0].init, r.matches[0].end, true }
+  if index < 1 || index >= r.catchIndex { return 0, 0, false }
+  return r.catches[index].init, r.catches[index].end, true
 }
 
-func (r *RE) LenCatch( index int ) int {
-  if index < 1 || index >= r.catchIndex { return 0 }
-  return r.catches[index].end - r.catches[index].init
+func (r *Matcher) GetCatch( index int ) string {
+  init, end, _ := r.span( index )
+  return r.txt[ init : end ]
+}
+
+// GetCatchByName returns the first catch of the hook "<{name}exp>"
+func (r *Matcher) GetCatchByName( name string ) string {
+  return r.GetCatch( r.firstIdCatch( r.IdByName( name ) ) )
+}
+
+// GetCatchBytes returns a catch as a subslice of the input of MatchBytes, or a
+// copy when the search was over a string
+func (r *Matcher) GetCatchBytes( index int ) []byte {
+  init, end, ok := r.span( index )
+  if !ok { return nil }
+  if r.bytes == nil { return []byte( r.txt[ init : end ] ) }
+
+  return r.bytes[ init : end : end ]
+}
+
+func (r *Matcher) GpsCatch( index int ) int {
+  init, _, _ := r.span( index )
+  return init
+}
+
+func (r *Matcher) LenCatch( index int ) int {
+  init, end, _ := r.span( index )
+  return end - init
+}
+
+func (r *Matcher) RplCatch( rplStr string, id int ) string {
+  result := r.rplCatch( rplStr, id )
+  if result == nil { return r.txt }
+
+  return string( result )
+}
+
+// RplCatchBytes is RplCatch over the input of MatchBytes, it always returns a
+// new slice
+func (r *Matcher) RplCatchBytes( rpl []byte, id int ) []byte {
+  result := r.rplCatch( bytesToString( rpl ), id )
+  if result == nil { return []byte( r.txt ) }
+
+  return result
 }
 
-func (r *RE) RplCatch( rplStr string, id int ) string {
+// rplCatch returns nil when there is no catch of id
+func (r *Matcher) rplCatch( rplStr string, id int ) []byte {
+  r.capture()
   last, rpls, catchLens := 0, 0, 0
   for index := 1; index < r.catchIndex; index++ {
     if r.catches[index].id == id {
@@ -578,7 +1604,7 @@ func (r *RE) RplCatch( rplStr string, id int ) string {
     }
   }
 
-  if rpls == 0 { return r.txt }
+  if rpls == 0 { return nil }
   if (r.mods & modFwrByChar) > 0 { catchLens = 0 }
 
   result, gps := make( []byte, len( r.txt ) - catchLens + rpls * len( rplStr ) ), 0
@@ -595,19 +1621,22 @@ func (r *RE) RplCatch( rplStr string, id int ) string {
 
   if last < len(r.txt) { gps += copy( result[gps:], r.txt[last:] ) }
 
-  return string( result[:gps] )
+  return result[:gps]
 }
 
-func (r *RE) PutCatch( pStr string ) (result string) {
+func (r *Matcher) PutCatch( pStr string ) (result string) {
   for i := 0; i < len(pStr); {
     if pStr[i] == '#' {
       i++
       if len(pStr[i:]) > 0 && pStr[i] == '#' {
         i++
         result += "#"
-      } else {
+      } else if n := backrefLen( pStr[i:] ); n > 1 && pStr[i] == '{' {
+        result += r.GetCatchByName( pStr[i + 1:i + n - 1] )
+        i      += n
+      } else if digits := countCharDigits( pStr[i:] ); digits > 0 {
         result += r.GetCatch( aToi( pStr[i:] ) )
-        i      += countCharDigits ( pStr[i:] )
+        i      += digits
       }
     } else { result += pStr[i:i+1]; i++ }
   }
@@ -615,16 +1644,255 @@ func (r *RE) PutCatch( pStr string ) (result string) {
   return
 }
 
+// FindAllString returns the text of the first n matches, all of them when
+// n < 0, or nil when there is no match
+func (r *Matcher) FindAllString( txt string, n int ) []string {
+  if n == 0 || r.scan( txt, n ) == 0 { return nil }
+
+  result := make( []string, len( r.matches ) )
+  for i, m := range r.matches { result[i] = txt[m.init:m.end] }
+
+  return result
+}
+
+// FindAllStringIndex returns the start and end positions of the first n
+// matches, all of them when n < 0
+func (r *Matcher) FindAllStringIndex( txt string, n int ) [][]int {
+  if n == 0 || r.scan( txt, n ) == 0 { return nil }
+
+  result := make( [][]int, len( r.matches ) )
+  for i, m := range r.matches { result[i] = []int{ m.init, m.end } }
+
+  return result
+}
+
+// FindAllStringSubmatchIndex returns for each of the first n matches its
+// start and end, followed by the start and end of the last catch of each hook
+// id inside the match, or -1 -1 when the hook did not catch
+func (r *Matcher) FindAllStringSubmatchIndex( txt string, n int ) [][]int {
+  if n == 0 || r.scan( txt, n ) == 0 { return nil }
+
+  result := make( [][]int, len( r.matches ) )
+  for i, m := range r.matches {
+    result[i] = make( []int, 2 * (r.hooks + 1) )
+    result[i][0], result[i][1] = m.init, m.end
+    for j := 2; j < len( result[i] ); j++ { result[i][j] = -1 }
+
+    for c, end := m.catch, r.matchCatchEnd( i ); c < end; c++ {
+      id := r.catches[c].id
+      result[i][2 * id], result[i][2 * id + 1] = r.catches[c].init, r.catches[c].end
+    }
+  }
+
+  return result
+}
+
+// matchCatchEnd returns the index after the last catch of the match n
+func (r *Matcher) matchCatchEnd( n int ) int {
+  if n + 1 < len( r.matches ) { return r.matches[n + 1].catch }
+
+  return r.catchIndex
+}
+
+// Match is one match of a search, its catches are indexed from 1 as if the
+// search had found only this match. It is valid inside the callback of
+// ReplaceAllStringFunc
+type Match struct {
+  view       Matcher
+  init, end  int
+}
+
+// matchView returns the view of the match n over the catches of the search
+func (r *Matcher) matchView( n int, m *Match ){
+  catch, end := r.matches[n].catch, r.matchCatchEnd( n )
+  m.init, m.end = r.matches[n].init, r.matches[n].end
+  m.view = Matcher{ Regexp: r.Regexp, txt: r.txt, catches: r.catches[catch - 1:end], catchIndex: end - catch + 1,
+                    matches: r.matches[n:n + 1] }
+}
+
+// String returns the text of the match
+func (m *Match) String() string { return m.view.txt[m.init:m.end] }
+
+// Span returns the start and end positions of the match in the text
+func (m *Match) Span() (int, int) { return m.init, m.end }
+
+func (m *Match) TotCatch() int                         { return m.view.TotCatch() }
+func (m *Match) GetCatch( index int ) string           { return m.view.GetCatch( index ) }
+func (m *Match) GpsCatch( index int ) int              { return m.view.GpsCatch( index ) }
+func (m *Match) LenCatch( index int ) int              { return m.view.LenCatch( index ) }
+func (m *Match) GetCatchByName( name string ) string   { return m.view.GetCatchByName( name ) }
+func (m *Match) PutCatch( pStr string ) string         { return m.view.PutCatch( pStr ) }
+
+// ReplaceAllString replaces each match of txt by template, where "#N" and
+// "#{name}" are the catches of that match as in PutCatch
+func (r *Matcher) ReplaceAllString( txt, template string ) string {
+  return r.ReplaceAllStringFunc( txt, func( m *Match ) string { return m.PutCatch( template ) } )
+}
+
+// ReplaceAllStringFunc replaces each match of txt by the result of f, the
+// matches that overlap a previous one (modifier "#~") are left as they are.
+// f must not search with r
+func (r *Matcher) ReplaceAllStringFunc( txt string, f func( m *Match ) string ) string {
+  if r.scan( txt, -1 ) == 0 { return txt }
+
+  var m Match
+  result, last := make( []byte, 0, len( txt ) ), 0
+  for n := range r.matches {
+    if r.matches[n].init < last { continue }
+
+    r.matchView( n, &m )
+    result = append( result, txt[last:m.init]... )
+    result = append( result, f( &m )... )
+    last   = m.end
+  }
+
+  return string( append( result, txt[last:]... ) )
+}
+
 func (r *RE) Copy() *RE {
-  nre := RE{ txt: r.txt, re: r.re, compile: r.compile, result: r.result, catchIndex: r.catchIndex, mods: r.mods }
+  r.capture()
+  nre := RE{ Matcher{ Regexp: r.Regexp, txt: r.txt, bytes: r.bytes, result: r.result, catchIndex: r.catchIndex, limit: r.limit } }
   nre.catches = make( []catchInfo, r.catchIndex )
   copy( nre.catches, r.catches )
-  nre.asm     = make( []raptorASM, len( r.asm ) )
-  copy( nre.asm, r.asm )
+  nre.matches = append( []matchInfo(nil), r.matches... )
 
   return &nre
 }
 
+var matcherPool = sync.Pool{ New: func() interface{} { return new( Matcher ) } }
+
+func (r *Regexp) getMatcher() *Matcher {
+  m := matcherPool.Get().(*Matcher)
+  m.Regexp, m.limit = r, r.stepLimit
+  return m
+}
+
+func putMatcher( m *Matcher ){
+  m.Regexp, m.txt, m.bytes, m.err, m.pending = nil, "", nil, nil, false
+  matcherPool.Put( m )
+}
+
+// MatchString is safe for concurrent use, the catches are discarded
+func (r *Regexp) MatchString( txt string ) int {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.MatchString( txt )
+}
+
+// FindString is safe for concurrent use, it stops at the first match
+func (r *Regexp) FindString( txt string ) bool {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.count( txt, 1 ) > 0
+}
+
+func (r *Regexp) MatchStringContext( ctx context.Context, txt string ) (int, error) {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.MatchStringContext( ctx, txt )
+}
+
+// WithStepLimit returns a copy of the expression whose searches stop with
+// ErrStepLimit after n steps of the engine, 0 is no limit
+func (r *Regexp) WithStepLimit( n int ) *Regexp {
+  c := *r
+  c.stepLimit = n
+  return &c
+}
+
+func (r *Regexp) MatchBytes( b []byte ) int {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.MatchBytes( b )
+}
+
+func (r *Regexp) FindBytes( b []byte ) bool {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.count( bytesToString( b ), 1 ) > 0
+}
+
+func (r *Regexp) ReplaceAllString( txt, template string ) string {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.ReplaceAllString( txt, template )
+}
+
+func (r *Regexp) ReplaceAllStringFunc( txt string, f func( m *Match ) string ) string {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.ReplaceAllStringFunc( txt, f )
+}
+
+func (r *Regexp) FindAllString( txt string, n int ) []string {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.FindAllString( txt, n )
+}
+
+func (r *Regexp) FindAllStringIndex( txt string, n int ) [][]int {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.FindAllStringIndex( txt, n )
+}
+
+func (r *Regexp) FindAllStringSubmatchIndex( txt string, n int ) [][]int {
+  m := r.getMatcher()
+  defer putMatcher( m )
+
+  return m.FindAllStringSubmatchIndex( txt, n )
+}
+
+// NewMatcher returns an independent search state over the program, to keep the
+// catches of each search
+func (r *Regexp) NewMatcher() *Matcher {
+  return &Matcher{ Regexp: r, limit: r.stepLimit }
+}
+
+func (r *Regexp) String() string { return r.re }
+
+// CatchNames returns the name of each hook by its id, names[0] and the unnamed
+// hooks are ""
+func (r *Regexp) CatchNames() []string {
+  names := make( []string, r.hooks + 1 )
+  copy( names, r.names )
+  return names
+}
+
+// IdByName returns the id of the hook "<{name}exp>", or -1 when there is no
+// such name
+func (r *Regexp) IdByName( name string ) int {
+  if r == nil || name == "" { return -1 }
+
+  for id, n := range r.names {
+    if n == name { return id }
+  }
+
+  return -1
+}
+
 func Compile( re string ) *RE {
   return new( RE ).Compile( re )
 }
+
+func CompileErr( re string ) (*RE, error) {
+  if err := checkSyntax( re ); err != nil { return nil, err }
+
+  return Compile( re ), nil
+}
+
+func MustCompile( re string ) *RE {
+  r, err := CompileErr( re )
+  if err != nil { panic( err ) }
+
+  return r
+}
@@ -16,6 +16,11 @@ package regexp4
 import "testing"
 import "fmt"
 import "bytes"
+import "reflect"
+import "strings"
+import "context"
+import "time"
+import "errors"
 
 func printASM( rexp *RE ){
   fmt.Printf( "                     init %q\n", rexp.re )
@@ -40,6 +45,11 @@ func printASM( rexp *RE ){
     case 13: fmt.Printf( "[%-12s]", "asmPoint"    )
     case 14: fmt.Printf( "[%-12s]", "asmSimple"   )
     case 15: fmt.Printf( "[%-12s]", "asmEnd"      )
+    case 16: fmt.Printf( "[%-12s]", "asmAhead"    )
+    case 17: fmt.Printf( "[%-12s]", "asmBehind"   )
+    case 18: fmt.Printf( "[%-12s]", "asmLookEnd"  )
+    case 19: fmt.Printf( "[%-12s]", "asmAnchor"   )
+    case 20: fmt.Printf( "[%-12s]", "asmClass"    )
     }
 
     fmt.Printf( " %-15q [%d-%d][%08b]\n", v.re.str, v.re.loopsMin, v.re.loopsMax, v.re.mods )
@@ -71,6 +81,30 @@ func TestRegexp4(t *testing.T) {
   sTestUTF( t )
   pTestUTF( t )
   gTestUTF( t )
+
+  eTest( t )
+  rTest( t )
+  bTest( t )
+  lTest( t )
+  aTest( t )
+  zTest( t )
+  mTest( t )
+  iTest( t )
+  fTest( t )
+  yTest( t )
+  wTest( t )
+  xTest( t )
+  kTest( t )
+  uTest( t )
+  oTest( t )
+  hTest( t )
+  jTest( t )
+  qTest( t )
+  vTest( t )
+  tTest( t )
+  vmTest( t )
+  dfaTest( t )
+  memoTest( t )
 }
 
 func nTest( t *testing.T ){
@@ -504,6 +538,12 @@ func nTest( t *testing.T ){
     { "a", "[a-z][a-z][a-z]", 0 },
     { "a aaa aaa", "[a-z]", 7 },
     { "a aaa aaa", "[ a-z]", 9 },
+    { "a-b", "[a-]", 2 },
+    { "-x ax bx cx", "[ab-]x", 3 },
+    { "-a", "[-a]", 2 },
+    { "\xe9\x80\ufffd", "[:xE9]", 0 },
+    { "\xe9\x80\ufffd", "[:x80-:xFF]", 0 },
+    { "A\x7f", "[:x41-:x7F]", 2 },
     { "a aaa aaa", "[a-z][a-z][a-z]", 2 },
     { "a aaa aaa", "[a-z]aa", 2 },
     { "a aaa aaa", "aa[a-z]", 2 },
@@ -1448,7 +1488,7 @@ func pTest( t *testing.T ){
     { "a", "<a>", "#x", "x" },
     { "a", "<a>", "#xx", "xx" },
     { "a", "<a>", "###1##", "#a#" },
-    { "a", "<a>", "[#0][#1][#2#3#1000000]", "[][a][]" },
+    { "a", "<a>", "[#0][#1][#2#3#1000000]", "[a][a][]" },
     { "aa", "<aa>", "#1", "aa" },
     { "a a a", "<a>", "#1#2#3", "aaa" },
     { "abcd", "<a|b|c|d>", "#4 #3 #2 #1", "d c b a" },
@@ -1456,7 +1496,7 @@ func pTest( t *testing.T ){
     { "Raptor Test", "<aptor|est>", "C#1 F#2", "Captor Fest" },
     { "Raptor Test", "<aptor|est>", "C#5 F#2", "C Fest" },
     { "Raptor Test", "<aptor|est>", "C#a F#2", "Ca Fest" },
-    { "Raptor Test", "<aptor|est>", "C#0 F#2", "C Fest" },
+    { "Raptor Test", "<aptor|est>", "C#0 F#2", "Captor Fest" },
     { "Raptor Test", "<aptor|est>", "C#43 F#43", "C F" },
     { "Raptor Test", "<aptor|est>", "C##43 ##F#43##", "C#43 #F#" },
     { "Raptor Test", "<aptor|est>", "C##43 ##1##2", "C#43 #1#2" },
@@ -1989,7 +2029,7 @@ func pTestUTF( t *testing.T ){
     { "▲", "<▲>", "#x", "x" },
     { "▲", "<▲>", "#xx", "xx" },
     { "▲", "<▲>", "###1##", "#▲#" },
-    { "▲", "<▲>", "[#0][#1][#2#3#1000000]", "[][▲][]" },
+    { "▲", "<▲>", "[#0][#1][#2#3#1000000]", "[▲][▲][]" },
     { "▲▲", "<▲▲>", "#1", "▲▲" },
     { "▲ ▲ ▲", "<▲>", "#1#2#3", "▲▲▲" },
     { "▲bcd", "<▲|b|c|d>", "#4 #3 #2 #1", "d c b ▲" },
@@ -1997,7 +2037,7 @@ func pTestUTF( t *testing.T ){
     { "R▲ptor Test", "<▲ptor|est>", "C#1 F#2", "C▲ptor Fest" },
     { "R▲ptor Test", "<▲ptor|est>", "C#5 F#2", "C Fest" },
     { "R▲ptor Test", "<▲ptor|est>", "C#▲ F#2", "C▲ Fest" },
-    { "R▲ptor Test", "<▲ptor|est>", "C#0 F#2", "C Fest" },
+    { "R▲ptor Test", "<▲ptor|est>", "C#0 F#2", "C▲ptor Fest" },
     { "R▲ptor Test", "<▲ptor|est>", "C#43 F#43", "C F" },
     { "R▲ptor Test", "<▲ptor|est>", "C##43 ##F#43##", "C#43 #F#" },
     { "R▲ptor Test", "<▲ptor|est>", "C##43 ##1##2", "C#43 #1#2" },
@@ -2046,6 +2086,1315 @@ func gTestUTF( t *testing.T ){
   }
 }
 
+func eTest( t *testing.T ){
+  errTest := []struct {
+    re string
+    offset int
+    expr string
+  }{
+    { "a", -1, "" },
+    { "<a>@1", -1, "" },
+    { "[/:-\\]", -1, "" },
+    { "#^$<:b*:|(:|+#*:|)+>", -1, "" },
+    { "a*+b{2,}+(c)?+", -1, "" },
+    { "a*?b{2,}?(c)??<d>{1,3}?", -1, "" },
+    { ":ia:f|:h(b:m|:M)c:e", -1, "" },
+    { "#^$!a|b#!", -1, "" },
+    { "<{year}:d{4}>-<{month}:d{2}>@{year}|<{year}x>@1", -1, "" },
+    { ":p{L}:P{Nd}[:p{Greek}:P{Han}a-z]:p:P", -1, "" },
+    { "(?=a)(?!b)(?<=c)(?<!d{1,3})(?<=e|ff|(g|<h>)i)@1", -1, "" },
+    { "<0?[1-9]|[12][0-9]|3[01]><[/:-\\]><0?[1-9]|1[012]>@2<[12][0-9]{3}>", -1, "" },
+
+    { "(a", 0, "(" },
+    { "a)", 1, ")" },
+    { "<a", 0, "<" },
+    { "(a>", 2, ">" },
+    { "<(a>)", 3, ">" },
+    { "[a", 0, "[" },
+    { "a{3,", 1, "{3," },
+    { "a{3", 1, "{3" },
+    { "a{5,2}", 1, "{5,2}" },
+    { "a{}", 1, "{}" },
+    { "a{,}", 1, "{,}" },
+    { "a**", 1, "**" },
+    { "a+++", 1, "+++" },
+    { "a*??", 1, "*??" },
+    { "a{2}?+", 1, "{2}?+" },
+    { "(?=a", 0, "(?=" },
+    { "(?=a)*", 5, "*" },
+    { "(?<!a){2}", 6, "{2}" },
+    { "x(?<=a+)", 1, "(?<=a+)" },
+    { "<a>(?<=:w@1)", 3, "(?<=:w@1)" },
+    { "a:m+", 3, "+" },
+    { "<{}a>", 1, "{}" },
+    { "<{a b}a>", 1, "{a " },
+    { "<{ab", 1, "{ab" },
+    { "<{a}x><{a}y>", 7, "{a}" },
+    { "<{a}x>|<{b}y>", 8, "{b}" },
+    { "<{a}x>|@{a}", 7, "@{a}" },
+    { "@{a}<{a}x>", 0, "@{a}" },
+    { "<x>@{", 4, "{" },
+    { "a:p{Bogus}", 1, ":p{Bogus}" },
+    { "[:P{Greek]", 1, ":P{Greek]" },
+    { "[:p{L}-z]", 1, ":p{L}-" },
+    { "(:i|b):f{1,2}", 8, "{1,2}" },
+    { "*a", 0, "*" },
+    { "(+a)", 1, "+" },
+    { "a|", 1, "|" },
+    { "|a", 0, "|" },
+    { "a||b", 2, "|" },
+    { "(|a)", 1, "|" },
+    { "a#x", 1, "#x" },
+    { "a#", 1, "#" },
+    { "#", 0, "#" },
+    { "#$a|#$b", 4, "#" },
+    { "@", 0, "@" },
+    { "@1", 0, "@1" },
+    { "<a>@2", 3, "@2" },
+    { "@1<a>", 0, "@1" },
+    { "a:", 1, ":" },
+    { "[]", 0, "[]" },
+    { "[^]", 0, "[^]" },
+    { "[z-a]", 1, "z-a" },
+    { "[a-]", 1, "a-" },
+    { "[-a]", 1, "-" },
+    { "[a-c-e]", 4, "-" },
+    { "[:a-z]", 1, ":a-" },
+    { "[я-а]", 1, "я-а" },
+    { "[ñ-:w]", 1, "ñ-" },
+    { "[а-я-ё]", 6, "-" },
+    { "[&&[a]]", 1, "&&[" },
+    { "[^--[a]]", 2, "--[" },
+    { "[a--[]]", 4, "[]" },
+    { "[a&&[b]c]", 7, "c" },
+    { "[a--[b]", 0, "[" },
+    { "[a&&[z-a]]", 5, "z-a" },
+    { "a:x4", 1, ":x" },
+    { "a:xg1", 1, ":x" },
+    { "[:u{}]", 1, ":u" },
+    { ":u{110000}", 0, ":u" },
+    { ":u{41", 0, ":u" },
+    { ":u{D800}", 0, ":u{D800}" },
+    { "[:u{44F}-:u{430}]", 1, ":u{44F}-:u{430}" },
+    { "[a-:x]", 3, ":x" },
+    { "[:xE9]", 1, ":xE9" },
+    { "[:x00-:xFF]", 6, ":xFF" },
+    { "[:x00-:x7F]", -1, "" },
+  }
+
+  for _, c := range errTest {
+    r, err := CompileErr( c.re )
+    if c.offset == -1 {
+      if err != nil || r == nil {
+        t.Errorf( "CompileErr( %q ) == %v, expected no error", c.re, err )
+      }
+      continue
+    }
+
+    serr, ok := err.(*SyntaxError)
+    if !ok || r != nil {
+      t.Errorf( "CompileErr( %q ) == %v, expected *SyntaxError", c.re, err )
+      continue
+    }
+
+    if serr.Offset != c.offset || serr.Expr != c.expr {
+      t.Errorf( "CompileErr( %q ) == [%d %q], expected [%d %q]",
+                c.re, serr.Offset, serr.Expr, c.offset, c.expr )
+    }
+  }
+
+  func(){
+    defer func(){
+      if recover() == nil { t.Errorf( "MustCompile( \"(a\" ): expected panic" ) }
+    }()
+
+    MustCompile( "(a" )
+  }()
+}
+
+func rTest( t *testing.T ){
+  shareTest := []struct {
+    txt, re string
+    n int
+  }{
+    { "Raptor Test", "Raptor|Test", 2 },
+    { "a aaa aaa", "aaa", 2 },
+    { "07-07-1777", "<0?[1-9]|[12][0-9]|3[01]><[/:-\\]><0?[1-9]|1[012]>@2<[12][0-9]{3}>", 1 },
+    { "nasciiboy@gmail.com car.re@me 42_666@info.hell", "<[_:w:-]+(:.[_:w:-]+)*>:@<:w+>(:.<:w+>)*", 3 },
+    { "△▲△▲△", "△|▲", 5 },
+  }
+
+  done := make(chan struct{})
+  for _, c := range shareTest {
+    var ref RE
+    ref.Match( c.txt, c.re )
+    prog := ref.Regexp
+
+    for i := 0; i < 8; i++ {
+      go func( txt, re string, n, catches int, last string ){
+        for j := 0; j < 64; j++ {
+          if x := prog.MatchString( txt ); x != n {
+            t.Errorf( "Regexp( %q ).MatchString( %q ) == %d, expected %d", re, txt, x, n )
+          }
+
+          m := prog.NewMatcher()
+          m.MatchString( txt )
+          if m.TotCatch() != catches || m.GetCatch( catches ) != last {
+            t.Errorf( "Matcher( %q ).MatchString( %q )\nTotCatch() == %d, expected %d",
+                      re, txt, m.TotCatch(), catches )
+          }
+        }
+        done <- struct{}{}
+      }( c.txt, c.re, c.n, ref.TotCatch(), ref.GetCatch( ref.TotCatch() ) )
+    }
+  }
+
+  for i := 0; i < len( shareTest ) * 8; i++ { <-done }
+}
+
+func bTest( t *testing.T ){
+  backTest := []struct {
+    txt, re string
+    n int
+    catch string
+  }{
+    { "aaab", "a*ab", 1, "" },
+    { "aaab", "<a*>ab", 1, "aa" },
+    { "aaab", "<a+>a+b", 1, "aa" },
+    { "aaab", "<a{1,3}>ab", 1, "aa" },
+    { "aaab", "<a?>aab", 1, "a" },
+    { "ab", "<a?>ab", 1, "" },
+    { "file.txt", "<.*>:.txt", 1, "file" },
+    { "a.b.txt", "<.*>:.txt", 1, "a.b" },
+    { "foo bar foo", "<.*>foo", 1, "foo bar " },
+    { "<a><b>", "<:<.*:>>", 1, "<a><b>" },
+    { "abc", "<a|ab>c", 1, "ab" },
+    { "abc", "(a|ab)<c>", 1, "c" },
+    { "abcabc", "<(a|ab)(c|bcd)>+", 1, "abcabc" },
+    { "abab", "<(ab)*>ab", 1, "ab" },
+    { "xaaay", "<(a)*>a", 1, "aa" },
+    { "aaa", "<a>+", 1, "aaa" },
+    { "aaaa", "<a{2}>+", 1, "aaaa" },
+    { "aaaaaaaa", "<:w+>a{3}", 1, "aaaaa" },
+    { "aaaaaaaa", "#$<.*>a{3}", 1, "aaaaa" },
+    { "07-07-1777", "<:d*>-<:d*>-<:d*>", 1, "07" },
+    { "xyz", "x<(y|yz)>z?", 1, "y" },
+    { "xyz", "x<(y|yz)>z", 1, "y" },
+    { "xyzz", "x<(y|yz)>z", 1, "y" },
+    { "xxy", "<x|x@1>y", 1, "x" },
+    { "xxyy", "<x+|x@1>y", 1, "xx" },
+
+    { "aaab", "a*+ab", 0, "" },
+    { "aaab", "<a++>b", 1, "aaa" },
+    { "aaab", "a{1,3}+ab", 0, "" },
+    { "ab", "a?+ab", 0, "" },
+    { "abab", "(ab)*+ab", 0, "" },
+    { "abab", "<(ab)*+>", 1, "abab" },
+    { "abc", "(a|ab)+c", 1, "" },
+    { "abc", "<(a|ab)++>c", 0, "" },
+    { "ac", "<(a|ab)++>c", 1, "a" },
+  }
+
+  for _, c := range backTest {
+    var r RE
+    if x := r.Match( c.txt, c.re ); x != c.n || r.GetCatch( 1 ) != c.catch {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch )
+    }
+  }
+}
+
+func lTest( t *testing.T ){
+  lazyTest := []struct {
+    txt, re string
+    n int
+    catch string
+    pos int
+  }{
+    { "aaa", "<a*?>", 3, "", 0 },
+    { "aaa", "<a+?>", 3, "a", 0 },
+    { "aaa", "<a??>", 3, "", 0 },
+    { "aaa", "<a{2,3}?>", 1, "aa", 0 },
+    { "aaa", "<a{2,}?>", 1, "aa", 0 },
+    { "aaab", "<a*?>b", 1, "aaa", 0 },
+    { "aaab", "<a+?>a", 1, "a", 0 },
+    { "<b>raptor</b>", "<:<.+?:>>", 2, "<b>", 0 },
+    { "<b>raptor</b>", ":<<.+?>:>", 2, "b", 1 },
+    { "say \"raptor\" and \"test\"", "\"<.*?>\"", 2, "raptor", 5 },
+    { "say \"raptor\" and \"test\"", "\"<.*>\"", 1, "raptor\" and \"test", 5 },
+    { "x12y34y", "x<.*?>y", 1, "12", 1 },
+    { "x12y34y", "x<[0-9y]*?>y", 1, "12", 1 },
+    { "x12y34y", "x<:w*?>y", 1, "12", 1 },
+    { "x12y34y", "x<(:d|y)*?>y", 1, "12", 1 },
+    { "x12y34y", "x<(:d|y)*>y", 1, "12y34", 1 },
+    { "abab", "<(ab)+?>", 2, "ab", 0 },
+    { "ababc", "<(ab)+?>c", 1, "abab", 0 },
+    { "ababc", "<(ab)??>ab", 2, "", 0 },
+    { "ababc", "#$<(ab)??>abc", 1, "ab", 0 },
+    { "ababc", "<<ab>{1,2}?>", 2, "ab", 0 },
+    { "△▲△▲", "<.+?>▲", 2, "△", 0 },
+  }
+
+  for _, c := range lazyTest {
+    var r RE
+    x := r.Match( c.txt, c.re )
+    if x != c.n || r.GetCatch( 1 ) != c.catch || r.GpsCatch( 1 ) != c.pos {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q\nGpsCatch( 1 ) == %d, expected %d",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch, r.GpsCatch( 1 ), c.pos )
+    }
+  }
+}
+
+func aTest( t *testing.T ){
+  lookTest := []struct {
+    txt, re string
+    n int
+    catch string
+    pos int
+  }{
+    { "10px 20em 30px", "<:d+>(?=px)", 2, "10", 0 },
+    { "10px 20em 30px", "<:d+>(?!px|:d)", 1, "20", 5 },
+    { "10px 20em 30px", "<:d+(?=px)>", 2, "10", 0 },
+    { "10px 20em 30px", "<:d+>(?=px)px", 2, "10", 0 },
+    { "10px 20em 30px", "<:d+(?=em):w+>", 1, "20em", 5 },
+    { "$10 20 $30", "(?<=$)<:d+>", 2, "10", 1 },
+    { "$10 20 $30", "(?<!$|:d)<:d+>", 1, "20", 4 },
+    { "$10 20 $30", "(?<![$:d])<:d+>", 1, "20", 4 },
+    { "US$10 20 $30", "(?<=US$|$)<:d+>", 2, "10", 3 },
+    { "€10 $20", "(?<=€)<:d+>", 1, "10", 3 },
+    { "€10 $20", "(?<=:&)<:d+>", 1, "10", 3 },
+    { "price: 10€ 20$", "<:d+>(?=€)", 1, "10", 7 },
+    { "aXbXc", "(?<=X)<:w>", 2, "b", 2 },
+    { "aXbXc", "(?<=:w{1,3})<X>", 2, "X", 1 },
+    { "abc", "(?=<a>)<.>", 1, "a", 0 },
+    { "abc", "(?!<a>)<.>", 2, "b", 1 },
+    { "abc", "(?=<x>)|<.>", 3, "a", 0 },
+    { "xay", "(?<=<x>)a", 1, "x", 0 },
+    { "aaab", "<a+>(?=b)", 1, "aaa", 0 },
+    { "aaab", "<a+?>(?=b)", 1, "aaa", 0 },
+    { "aaab", "<a*>(?!a)", 2, "aaa", 0 },
+    { "foobar foobaz", "foo(?!bar)<:w+>", 1, "baz", 10 },
+    { "foobar foobaz", "<:w+>(?<=bar)", 1, "foobar", 0 },
+    { "foobar foobaz", "<:w+>(?<!bar)", 2, "fooba", 0 },
+    { "foobar foobaz", "<:w+>(?<!bar)(?!:w)", 1, "foobaz", 7 },
+  }
+
+  for _, c := range lookTest {
+    var r RE
+    x := r.Match( c.txt, c.re )
+    if x != c.n || r.GetCatch( 1 ) != c.catch || r.GpsCatch( 1 ) != c.pos {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q\nGpsCatch( 1 ) == %d, expected %d",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch, r.GpsCatch( 1 ), c.pos )
+    }
+  }
+}
+
+func zTest( t *testing.T ){
+  anchorTest := []struct {
+    txt, re string
+    n int
+    catch string
+    pos int
+  }{
+    { "Raptor Test", ":iRaptor", 1, "", 0 },
+    { "Raptor Test", ":iTest", 0, "", 0 },
+    { "Raptor Test", "Test:f", 1, "", 0 },
+    { "Raptor Test", "Raptor:f", 0, "", 0 },
+    { "Raptor Test", "<:iRaptor|Test:f>", 2, "Raptor", 0 },
+    { "Raptor Test", "<:iTest|Raptor:f>", 0, "", 0 },
+    { "Raptor Test", "#*<:iRaPtOr|TeSt:f>", 2, "Raptor", 0 },
+    { "Raptor Test", "(:i|:b)<:w>", 2, "R", 0 },
+    { "one\ntwo\nthree", ":h<:w+>", 3, "one", 0 },
+    { "one\ntwo\nthree", "<:w+>:e", 3, "one", 0 },
+    { "one\ntwo\nthree", "<:w+>:f", 1, "three", 8 },
+    { "one\ntwo\nthree", ":i<:w+>", 1, "one", 0 },
+    { "one\ntwo\nthree", ":h<t:w+>:e", 2, "two", 4 },
+    { "one\ntwo\nthree", "<o>:e", 1, "o", 6 },
+    { "one\n\nthree", ":h<:e>", 1, "", 4 },
+    { "cat concat cats", ":mcat:m", 1, "", 0 },
+    { "cat concat cats", "<:mcat>:M", 1, "cat", 11 },
+    { "cat concat cats", ":M<cat>", 1, "cat", 7 },
+    { "cat concat cats", "<:m:w+:m>", 3, "cat", 0 },
+    { "cat concat cats", "<:w*:m>s", 0, "", 0 },
+    { "a1 b2", "<:d>:m", 2, "1", 1 },
+    { "a1 b2", ":m<:d>", 0, "", 0 },
+    { "a1 b2", "<(:m:w)+>", 2, "a", 0 },
+    { "abc", "(?<=:ia)<b>", 1, "b", 1 },
+    { "abc", "<b>(?=c:f)", 1, "b", 1 },
+  }
+
+  for _, c := range anchorTest {
+    var r RE
+    x := r.Match( c.txt, c.re )
+    if x != c.n || r.GetCatch( 1 ) != c.catch || r.GpsCatch( 1 ) != c.pos {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q\nGpsCatch( 1 ) == %d, expected %d",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch, r.GpsCatch( 1 ), c.pos )
+    }
+  }
+}
+
+func mTest( t *testing.T ){
+  log := "error: disk\nwarn: cpu\nerror: net"
+  multilineTest := []struct {
+    txt, re string
+    n, catches int
+    first, last string
+  }{
+    { log, "#^error", 1, 0, "", "" },
+    { log, "#^!error", 2, 0, "", "" },
+    { log, "#^!<:w+>", 3, 3, "error", "error" },
+    { log, "#^!warn", 1, 0, "", "" },
+    { log, "#^!cpu", 0, 0, "", "" },
+    { log, "#$<:w+>", 1, 1, "net", "net" },
+    { log, "#$!<:w+>", 3, 3, "disk", "net" },
+    { log, "#$!error", 0, 0, "", "" },
+    { log, "#^$!<:w+>:: <:w+>", 3, 6, "error", "net" },
+    { log, "#^$!<error>:: <:w+>", 2, 4, "error", "net" },
+    { log, "#^$!<error>:: <c:w+>", 0, 0, "", "" },
+    { log, "#^!<:w+:: :w+>:e", 3, 3, "error: disk", "error: net" },
+    { log, "#^!?<:w+>", 1, 1, "error", "error" },
+    { "ab\nab\nab", "#^$!ab", 3, 0, "", "" },
+    { "ab\nab\nab", "#^!b", 0, 0, "", "" },
+    { "ab\nab\nab", "#$!a", 0, 0, "", "" },
+    { "ab\n\nab", "#^!<:w*>", 3, 3, "ab", "ab" },
+    { "ab\r\nab", "#$!<b:s?>", 2, 2, "b\r", "b" },
+    { "AB\nab", "#^$!*<ab>", 2, 2, "AB", "ab" },
+  }
+
+  for _, c := range multilineTest {
+    var r RE
+    x := r.Match( c.txt, c.re )
+    last := ""
+    if r.TotCatch() > 0 { last = r.GetCatch( r.TotCatch() ) }
+
+    if x != c.n || r.TotCatch() != c.catches || r.GetCatch( 1 ) != c.first || last != c.last {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nTotCatch() == %d, expected %d\nfirst/last catch == %q %q, expected %q %q",
+                c.txt, c.re, x, c.n, r.TotCatch(), c.catches,
+                r.GetCatch( 1 ), last, c.first, c.last )
+    }
+  }
+}
+
+func iTest( t *testing.T ){
+  var r RE
+  date := "<{year}:d{4}>-<{month}:d{2}>-<{day}:d{2}>"
+  r.Match( "from 2017-03-12 to 2018-11-05", date )
+
+  if names := r.CatchNames(); len( names ) != 4 || names[0] != "" || names[1] != "year" || names[3] != "day" {
+    t.Errorf( "CatchNames() == %q, expected [\"\" \"year\" \"month\" \"day\"]", names )
+  }
+
+  for name, id := range map[string]int{ "year": 1, "month": 2, "day": 3, "hour": -1, "": -1 } {
+    if x := r.IdByName( name ); x != id {
+      t.Errorf( "IdByName( %q ) == %d, expected %d", name, x, id )
+    }
+  }
+
+  for name, catch := range map[string]string{ "year": "2017", "month": "03", "day": "12", "hour": "" } {
+    if x := r.GetCatchByName( name ); x != catch {
+      t.Errorf( "GetCatchByName( %q ) == %q, expected %q", name, x, catch )
+    }
+  }
+
+  if x := r.PutCatch( "#{day}/#{month}/#{year} ##{day} #3 #{hour}" ); x != "12/03/2017 #{day} 12 " {
+    t.Errorf( "PutCatch() == %q, expected %q", x, "12/03/2017 #{day} 12 " )
+  }
+
+  if x := r.RplCatch( "YYYY", r.IdByName( "year" ) ); x != "from YYYY-03-12 to YYYY-11-05" {
+    t.Errorf( "RplCatch() == %q, expected %q", x, "from YYYY-03-12 to YYYY-11-05" )
+  }
+
+  nameTest := []struct {
+    txt, re string
+    n int
+    catch string
+  }{
+    { "abab cdcd abcd", "<{pair}:w:w>@{pair}", 2, "ab" },
+    { "abab cdcd abcd", "<{pair}:w:w>@1", 2, "ab" },
+    { "<b>bold</b> <i>x</b>", ":<<{tag}:w+>:>:w+:<:/@{tag}:>", 1, "b" },
+    { "aa bb ab", "<{c}:w>@{c}|<{c}:d>", 2, "a" },
+    { "x=1 y=2", "<{key}:w>=<{value}:d>", 2, "x" },
+    { "abc", "<{x}b>|<a>", 2, "a" },
+  }
+
+  for _, c := range nameTest {
+    x := r.Match( c.txt, c.re )
+    if x != c.n || r.GetCatch( 1 ) != c.catch {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch )
+    }
+  }
+
+  var zero RE
+  if x := zero.GetCatchByName( "x" ); x != "" {
+    t.Errorf( "GetCatchByName() on an empty RE == %q, expected \"\"", x )
+  }
+}
+
+func fTest( t *testing.T ){
+  findAllTest := []struct {
+    txt, re string
+    n int
+    all []string
+    index, submatch [][]int
+  }{
+    { "Raptor Test", "x", -1, nil, nil, nil },
+    { "Raptor Test", "t", 0, nil, nil, nil },
+    { "Raptor Test", "t", -1, []string{ "t", "t" },
+      [][]int{ { 3, 4 }, { 10, 11 } }, [][]int{ { 3, 4 }, { 10, 11 } } },
+    { "Raptor Test", "#*t", -1, []string{ "t", "T", "t" },
+      [][]int{ { 3, 4 }, { 7, 8 }, { 10, 11 } }, [][]int{ { 3, 4 }, { 7, 8 }, { 10, 11 } } },
+    { "Raptor Test", "#*t", 2, []string{ "t", "T" },
+      [][]int{ { 3, 4 }, { 7, 8 } }, [][]int{ { 3, 4 }, { 7, 8 } } },
+    { "x=1, yy=22", "<:w+>=<:d+>", -1, []string{ "x=1", "yy=22" },
+      [][]int{ { 0, 3 }, { 5, 10 } }, [][]int{ { 0, 3, 0, 1, 2, 3 }, { 5, 10, 5, 7, 8, 10 } } },
+    { "ab a", "<a>(<b>)?", -1, []string{ "ab", "a" },
+      [][]int{ { 0, 2 }, { 3, 4 } }, [][]int{ { 0, 2, 0, 1, 1, 2 }, { 3, 4, 3, 4, -1, -1 } } },
+    { "abc", "<:w>+", -1, []string{ "abc" },
+      [][]int{ { 0, 3 } }, [][]int{ { 0, 3, 0, 3 } } },
+    { "a1 b", "<:a>|<:d>", -1, []string{ "a", "1", "b" },
+      [][]int{ { 0, 1 }, { 1, 2 }, { 3, 4 } }, [][]int{ { 0, 1, 0, 1 }, { 1, 2, 1, 2 }, { 3, 4, 3, 4 } } },
+    { "aaa", "#~<a+>", -1, []string{ "aaa", "aa", "a" },
+      [][]int{ { 0, 3 }, { 1, 3 }, { 2, 3 } }, [][]int{ { 0, 3, 0, 3 }, { 1, 3, 1, 3 }, { 2, 3, 2, 3 } } },
+    { "ab", "a*", -1, []string{ "a", "" },
+      [][]int{ { 0, 1 }, { 1, 1 } }, [][]int{ { 0, 1 }, { 1, 1 } } },
+    { "ab ab", "#?ab", -1, []string{ "ab" },
+      [][]int{ { 0, 2 } }, [][]int{ { 0, 2 } } },
+  }
+
+  for _, c := range findAllTest {
+    var r RE
+    r.Compile( c.re )
+    if x := r.FindAllString( c.txt, c.n ); !reflect.DeepEqual( x, c.all ) {
+      t.Errorf( "FindAllString( %q, %d ) with %q == %q, expected %q", c.txt, c.n, c.re, x, c.all )
+    }
+
+    if x := r.FindAllStringIndex( c.txt, c.n ); !reflect.DeepEqual( x, c.index ) {
+      t.Errorf( "FindAllStringIndex( %q, %d ) with %q == %v, expected %v", c.txt, c.n, c.re, x, c.index )
+    }
+
+    if x := r.Regexp.FindAllStringSubmatchIndex( c.txt, c.n ); !reflect.DeepEqual( x, c.submatch ) {
+      t.Errorf( "FindAllStringSubmatchIndex( %q, %d ) with %q == %v, expected %v", c.txt, c.n, c.re, x, c.submatch )
+    }
+  }
+}
+
+func yTest( t *testing.T ){
+  bytesTest := []struct {
+    txt, re string
+  }{
+    { "Raptor Test", "<Raptor|Test>" },
+    { "Raptor Test", "#*<t>" },
+    { "x=1, yy=22", "<:w+>=<:d+>" },
+    { "Ramón Ñandú", "<:&>" },
+    { "abab cdcd", "<:w:w>@1" },
+    { "Raptor Test", "x" },
+    { "", "<a*>" },
+  }
+
+  for _, c := range bytesTest {
+    var r RE
+    r.Compile( c.re )
+    n := r.MatchString( c.txt )
+    catches := make( []string, r.TotCatch() + 1 )
+    for i := range catches { catches[i] = r.GetCatch( i ) }
+    rpl := r.RplCatch( "_", 1 )
+
+    b := []byte( c.txt )
+    if x := r.MatchBytes( b ); x != n || r.FindBytes( b ) != (n > 0) || r.Regexp.MatchBytes( b ) != n {
+      t.Errorf( "MatchBytes( %q ) with %q == %d, expected %d", c.txt, c.re, x, n )
+    }
+
+    for i := range catches {
+      if x := r.GetCatchBytes( i ); string( x ) != catches[i] {
+        t.Errorf( "GetCatchBytes( %d ) with %q, %q == %q, expected %q", i, c.txt, c.re, x, catches[i] )
+      }
+    }
+
+    if x := r.RplCatchBytes( []byte( "_" ), 1 ); string( x ) != rpl {
+      t.Errorf( "RplCatchBytes() with %q, %q == %q, expected %q", c.txt, c.re, x, rpl )
+    }
+  }
+
+  var r RE
+  b := []byte( "key=value" )
+  r.Compile( "<:w+>=<:w+>" ).MatchBytes( b )
+
+  if catch := r.GetCatchBytes( 2 ); len( catch ) != 5 || &catch[0] != &b[4] || cap( catch ) != 5 {
+    t.Errorf( "GetCatchBytes( 2 ) is not a subslice of the input" )
+  }
+
+  if x := r.RplCatchBytes( []byte( "v" ), 2 ); string( x ) != "key=v" || string( b ) != "key=value" {
+    t.Errorf( "RplCatchBytes() == %q, input %q, expected \"key=v\", \"key=value\"", x, b )
+  }
+
+  r.MatchString( "a=b" )
+  if x := r.GetCatchBytes( 1 ); string( x ) != "a" {
+    t.Errorf( "GetCatchBytes( 1 ) after MatchString == %q, expected \"a\"", x )
+  }
+
+  allocs := testing.AllocsPerRun( 10, func(){
+    r.MatchBytes( b )
+    r.GetCatchBytes( 1 )
+  } )
+
+  if allocs != 0 {
+    t.Errorf( "MatchBytes() and GetCatchBytes() allocate %v times, expected 0", allocs )
+  }
+}
+
+// runeReader hides the methods of strings.Reader other than ReadRune
+type runeReader struct { rd *strings.Reader }
+
+func (r *runeReader) ReadRune() (rune, int, error) { return r.rd.ReadRune() }
+
+// failReader reads its text and then fails with err
+type failReader struct {
+  rd  *strings.Reader
+  err error
+}
+
+func (r *failReader) ReadRune() (rune, int, error) {
+  c, n, err := r.rd.ReadRune()
+  if err != nil { err = r.err }
+  return c, n, err
+}
+
+func wTest( t *testing.T ){
+  long := strings.Repeat( "lorem ipsum dolor 1234 sit\n", 2000 )
+  readerTest := []struct {
+    txt, re string
+  }{
+    { "Raptor Test", "Raptor|Test" },
+    { "Raptor Test", "#*t" },
+    { "Raptor Test", "#~:w+" },
+    { "Raptor Test", "#?:w+" },
+    { "Raptor Test", "#^Raptor" },
+    { "Raptor Test", "#^Test" },
+    { "Raptor Test", "#$:w+" },
+    { "Raptor Test", ":w+:f" },
+    { "Raptor Test", ":i:w+" },
+    { "Raptor Test", ":m:w" },
+    { "Ramón Ñandú", ":&:w*" },
+    { "abab cdcd abcd", "<:w:w>@1" },
+    { "$10 20 $30", "(?<=$):d+" },
+    { "$10 20 $30", "(?<![$:d]):d+" },
+    { "10px 20em 30px", ":d+(?=px)" },
+    { "<b>Raptor</b>", ":<.+?:>" },
+    { long, "dolor" },
+    { long, ":d+" },
+    { long, "#^!lorem" },
+    { long, "#$!<:w+>" },
+    { long, ":hl:w+" },
+    { long, "(?<=dolor ):d+" },
+    { long, "ipsum.*sit" },
+    { long, "#!sit:e" },
+    { long, "sit:f" },
+    { long + "end", "e.*d" },
+  }
+
+  for _, c := range readerTest {
+    r := MustCompile( c.re )
+    index := r.FindAllStringIndex( c.txt, -1 )
+
+    if x := r.FindAllReaderIndex( &runeReader{ strings.NewReader( c.txt ) }, -1 ); !reflect.DeepEqual( x, index ) {
+      t.Errorf( "FindAllReaderIndex() with %.20q, %q == %.60v, expected %.60v", c.txt, c.re, x, index )
+    }
+
+    if x := r.Regexp.MatchReader( strings.NewReader( c.txt ) ); x != len( index ) {
+      t.Errorf( "MatchReader() with %.20q, %q == %d, expected %d", c.txt, c.re, x, len( index ) )
+    }
+
+    if x := r.FindReaderIndex( strings.NewReader( c.txt ) ); len( index ) > 0 && !reflect.DeepEqual( x, index[0] ) || len( index ) == 0 && x != nil {
+      t.Errorf( "FindReaderIndex() with %.20q, %q == %v", c.txt, c.re, x )
+    }
+  }
+
+  if x := MustCompile( "<:w+>" ).FindAllReaderIndex( strings.NewReader( "ab\xffcd\xfe\xfeé" ), -1 ); !reflect.DeepEqual( x, [][]int{ { 0, 2 }, { 3, 5 } } ) {
+    t.Errorf( "FindAllReaderIndex() over invalid UTF-8 == %v, expected [[0 2] [3 5]]", x )
+  }
+
+  if x := MustCompile( ":d+" ).FindAllReaderIndex( strings.NewReader( long ), 2 ); !reflect.DeepEqual( x, [][]int{ { 18, 22 }, { 45, 49 } } ) {
+    t.Errorf( "FindAllReaderIndex( n = 2 ) == %v, expected [[18 22] [45 49]]", x )
+  }
+
+  fail := errors.New( "disk error" )
+  m := MustCompile( ":d+" ).Regexp.NewMatcher()
+  if x := m.FindAllReaderIndex( &failReader{ strings.NewReader( "a1 b22" ), fail }, -1 ); !reflect.DeepEqual( x, [][]int{ { 1, 2 }, { 4, 6 } } ) || m.Err() != fail {
+    t.Errorf( "FindAllReaderIndex() with a read error == %v, %v, expected [[1 2] [4 6]], %v", x, m.Err(), fail )
+  }
+
+  if x := m.MatchReader( strings.NewReader( "a1 b22" ) ); x != 2 || m.Err() != nil {
+    t.Errorf( "MatchReader() == %d, %v, expected 2, <nil>", x, m.Err() )
+  }
+}
+
+func xTest( t *testing.T ){
+  replaceTest := []struct {
+    txt, re, template, result string
+  }{
+    { "Raptor Test", "x", "y", "Raptor Test" },
+    { "Raptor Test", "t", "T", "RapTor TesT" },
+    { "Raptor Test", "#*<t>", "[#1]", "Rap[t]or [T]es[t]" },
+    { "Raptor Test", "<:w+>", "## #1 ##", "# Raptor # # Test #" },
+    { "x=1, yy=22", "<:w+>=<:d+>", "#2=#1", "1=x, 22=yy" },
+    { "x=1, yy=22", "<:w+>=<:d+>", "#3", ", " },
+    { "2017-03-12 2018-11-05", "<{y}:d+>-<{m}:d+>-<{d}:d+>", "#{d}/#{m}/#{y}", "12/03/2017 05/11/2018" },
+    { "ab a", "<a>(<b>)?", "(#2)", "(b) ()" },
+    { "aaa", "#~a+", "b", "b" },
+    { "Raptor Test", "#?:w+", "w", "w Test" },
+  }
+
+  for _, c := range replaceTest {
+    r := Compile( c.re )
+    if x := r.ReplaceAllString( c.txt, c.template ); x != c.result {
+      t.Errorf( "ReplaceAllString( %q, %q ) with %q == %q, expected %q", c.txt, c.template, c.re, x, c.result )
+    }
+
+    if x := r.Regexp.ReplaceAllString( c.txt, c.template ); x != c.result {
+      t.Errorf( "Regexp.ReplaceAllString( %q, %q ) with %q == %q, expected %q", c.txt, c.template, c.re, x, c.result )
+    }
+  }
+
+  r := Compile( "<:d+><:a+>" )
+  x := r.ReplaceAllStringFunc( "10px 20em", func( m *Match ) string {
+    init, end := m.Span()
+    if m.TotCatch() != 2 || m.GetCatch( 3 ) != "" || m.LenCatch( 1 ) != 2 || m.GpsCatch( 2 ) != init + 2 || end - init != len( m.String() ) {
+      t.Errorf( "Match %q with TotCatch() %d, Span() %d %d, GpsCatch( 2 ) %d", m.String(), m.TotCatch(), init, end, m.GpsCatch( 2 ) )
+    }
+
+    return m.GetCatch( 2 ) + m.GetCatch( 1 )
+  } )
+
+  if x != "px10 em20" {
+    t.Errorf( "ReplaceAllStringFunc() == %q, expected %q", x, "px10 em20" )
+  }
+
+  x = MustCompile( "<{word}:a+>" ).Regexp.ReplaceAllStringFunc( "Raptor Test", func( m *Match ) string {
+    return strings.ToUpper( m.GetCatchByName( "word" ) )
+  } )
+
+  if x != "RAPTOR TEST" {
+    t.Errorf( "ReplaceAllStringFunc() == %q, expected %q", x, "RAPTOR TEST" )
+  }
+}
+
+func kTest( t *testing.T ){
+  spanTest := []struct {
+    txt, re string
+    catch0 string
+    spans [][]int
+  }{
+    { "Raptor Test", "x", "", nil },
+    { "Raptor Test", "Test", "Test", [][]int{ { 7, 11 } } },
+    { "Raptor Test", "#*t", "t", [][]int{ { 3, 4 }, { 7, 8 }, { 10, 11 } } },
+    { "x=1, yy=22", "<:w+>=<:d+>", "x=1", [][]int{ { 0, 3 }, { 5, 10 } } },
+    { "aaa", "#~a+", "aaa", [][]int{ { 0, 3 }, { 1, 3 }, { 2, 3 } } },
+    { "ab", "a*", "a", [][]int{ { 0, 1 }, { 1, 1 } } },
+  }
+
+  for _, c := range spanTest {
+    var r RE
+    r.Match( c.txt, c.re )
+    init, end := -1, -1
+    if len( c.spans ) > 0 { init, end = c.spans[0][0], c.spans[0][1] }
+
+    if r.GetCatch( 0 ) != c.catch0 || string( r.GetCatchBytes( 0 ) ) != c.catch0 || len( c.spans ) > 0 && (r.GpsCatch( 0 ) != init || r.LenCatch( 0 ) != end - init) {
+      t.Errorf( "Regexp4( %q, %q ) catch 0 == %q %d %d, expected %q %d %d", c.txt, c.re,
+                r.GetCatch( 0 ), r.GpsCatch( 0 ), r.LenCatch( 0 ), c.catch0, init, end - init )
+    }
+
+    if r.MatchCount() != len( c.spans ) {
+      t.Errorf( "Regexp4( %q, %q ) MatchCount() == %d, expected %d", c.txt, c.re, r.MatchCount(), len( c.spans ) )
+    }
+
+    for n := 0; n <= len( c.spans ) + 1; n++ {
+      init, end := r.MatchSpan( n )
+      expected := []int{ -1, -1 }
+      if n > 0 && n <= len( c.spans ) { expected = c.spans[n - 1] }
+
+      if init != expected[0] || end != expected[1] {
+        t.Errorf( "Regexp4( %q, %q ) MatchSpan( %d ) == %d %d, expected %v", c.txt, c.re, n, init, end, expected )
+      }
+    }
+  }
+
+  var r RE
+  r.Compile( "<:w+>=<:d+>" )
+  if x := r.ReplaceAllString( "x=1, yy=22", "[#0]" ); x != "[x=1], [yy=22]" {
+    t.Errorf( "ReplaceAllString() with #0 == %q, expected %q", x, "[x=1], [yy=22]" )
+  }
+
+  r.ReplaceAllStringFunc( "x=1, yy=22", func( m *Match ) string {
+    if init, _ := m.Span(); m.GetCatch( 0 ) != m.String() || m.GpsCatch( 0 ) != init {
+      t.Errorf( "Match.GetCatch( 0 ) == %q at %d, expected %q at %d", m.GetCatch( 0 ), m.GpsCatch( 0 ), m.String(), init )
+    }
+
+    return ""
+  } )
+
+  var zero RE
+  if zero.GetCatch( 0 ) != "" || zero.MatchCount() != 0 {
+    t.Errorf( "catch 0 of an empty RE == %q, MatchCount() == %d", zero.GetCatch( 0 ), zero.MatchCount() )
+  }
+}
+
+func uTest( t *testing.T ){
+  unicodeTest := []struct {
+    txt, re string
+    n int
+    catch string
+    pos int
+  }{
+    { "ñandú", "<:a+>", 1, "and", 2 },
+    { "ñandú", "#&<:a+>", 1, "ñandú", 0 },
+    { "ñandú ñu", "<:a+#&>", 2, "ñandú", 0 },
+    { "ñandú ñu", "#&<:A>", 1, " ", 7 },
+    { "a\u00a0b\u2003c", "<:s>", 0, "", 0 },
+    { "a\u00a0b\u2003c", "#&<:s>", 2, "\u00a0", 1 },
+    { "a\u00a0b\u2003c", "#&<:S>", 3, "a", 0 },
+    { "a\u00a0b\tc\n", "#&<:b>", 2, "\u00a0", 1 },
+    { "١٢٣ 123", "<:d+>", 1, "123", 7 },
+    { "١٢٣ 123", "#&<:d+>", 2, "١٢٣", 0 },
+    { "١٢٣ 123", "#&<:D>", 1, " ", 6 },
+    { "日本語 text", "#&<:w+>", 2, "日本語", 0 },
+    { "日本語 text", "#&<:W+>", 1, " ", 9 },
+    { "ñ1 x", "#&<[:a]>", 2, "ñ", 0 },
+    { "ñ1 x", "#&<[^:a:s]>", 1, "1", 2 },
+    { "мир world", "<[а-я]+>", 1, "мир", 0 },
+    { "мир world", "<[^а-я ]+>", 1, "world", 7 },
+    { "МИР", "<[а-я]+#*>", 1, "МИР", 0 },
+    { "漢字かな", "<[一-鿿]+>", 1, "漢字", 0 },
+    { "año", "<[a-zà-ÿ]+>", 1, "año", 0 },
+    { "x-ñ", "<[ñ:-]+>", 1, "-ñ", 1 },
+    { "😀😃x", "<[😀-😆]+>", 1, "😀😃", 0 },
+    { "b\xffc", "<[a-ÿ]+>", 2, "b", 0 },
+    { "xüber über", ":m<über>", 1, "über", 1 },
+    { "xüber über", "#&:m<über>", 1, "über", 7 },
+    { "xüber über", "#&:M<über>", 1, "über", 1 },
+    { "añb", "#&(?<=:a)<b>", 1, "b", 3 },
+    { "añb", "#&(?<=a:a)<b>", 1, "b", 3 },
+    { "a\xffb", "#&<:A>", 1, "\xff", 1 },
+    { "αβγ abc", "<:p{Greek}+>", 1, "αβγ", 0 },
+    { "αβγ abc", "<:P{Greek}+>", 1, " abc", 6 },
+    { "漢字かな", "<:p{Han}+>", 1, "漢字", 0 },
+    { "漢字かな", "<:p{Hiragana}+>", 1, "かな", 6 },
+    { "Ab1 Ñ", "<:p{Lu}>", 2, "A", 0 },
+    { "Ab1 Ñ", "<[:p{Ll}:d]+>", 1, "b1", 1 },
+    { "Ab1 Ñ", "<[^:p{L}]>", 2, "1", 2 },
+    { "Ab1 Ñ", "<[:P{L}]+>", 1, "1 ", 2 },
+    { "x€ y$", "<:p{Sc}>", 2, "€", 1 },
+    { "añb", "(?<=:p{L})<b>", 1, "b", 3 },
+    { "añb", "(?<=:p{Latin}{2})<b>", 1, "b", 3 },
+    { "pp{", "<:p+>", 1, "pp", 0 },
+    { "p{L}", "<:p{L}>", 2, "p", 0 },
+  }
+
+  for _, c := range unicodeTest {
+    var r RE
+    x := r.Match( c.txt, c.re )
+    if x != c.n || r.GetCatch( 1 ) != c.catch || r.GpsCatch( 1 ) != c.pos {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q\nGpsCatch( 1 ) == %d, expected %d",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch, r.GpsCatch( 1 ), c.pos )
+    }
+  }
+}
+
+func oTest( t *testing.T ){
+  foldTest := []struct {
+    txt, re string
+    n int
+    catch string
+    pos int
+  }{
+    { "el árbol", "#*<ÁRBOL>", 1, "árbol", 3 },
+    { "EL ÁRBOL", "#*<árbol>", 1, "ÁRBOL", 3 },
+    { "el árbol", "<ÁRBOL>", 0, "", 0 },
+    { "Éé", "<[É]#*>", 2, "É", 0 },
+    { "xyz", "<[É]#*>", 0, "", 0 },
+    { "xÉy", "<[^é]#*>", 2, "x", 0 },
+    { "ΣΑΣ σας", "#*<σας>", 2, "ΣΑΣ", 0 },
+    { "ς", "#*<Σ>", 1, "ς", 0 },
+    { "K", "#*<k>", 1, "K", 0 },
+    { "k", "#*<K>", 1, "k", 0 },
+    { "Kel", "#*<[k]el>", 1, "Kel", 0 },
+    { "K", "#*<[a-z]>", 1, "K", 0 },
+    { "ſ", "#*<S>", 1, "ſ", 0 },
+    { "ı", "#*<i>", 0, "", 0 },
+    { "İ", "#*<i>", 0, "", 0 },
+    { "ı", "#*<I>", 0, "", 0 },
+    { "Äpfel äpfel", "#&<:w+> @1", 0, "", 0 },
+    { "Äpfel äpfel", "#&*<:w+> @1", 1, "Äpfel", 0 },
+    { "Äpfel äpfel", "#*<äpfel> @1", 1, "Äpfel", 0 },
+    { "ǅ ǆ Ǆ", "#*<ǆ> @1 @1", 1, "ǅ", 0 },
+    { "ÑU ñu", "#*(?<=ñu:s)<ÑU>", 1, "ñu", 4 },
+  }
+
+  for _, c := range foldTest {
+    var r RE
+    x := r.Match( c.txt, c.re )
+    if x != c.n || r.GetCatch( 1 ) != c.catch || r.GpsCatch( 1 ) != c.pos {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q\nGpsCatch( 1 ) == %d, expected %d",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch, r.GpsCatch( 1 ), c.pos )
+    }
+  }
+}
+
+func hTest( t *testing.T ){
+  setTest := []struct {
+    txt, re string
+    n int
+    catch string
+    pos int
+  }{
+    { "raptor", "<[a-z--[aeiou]]+>", 3, "r", 0 },
+    { "raptor", "<[:a--[aeiou]]>", 4, "r", 0 },
+    { "a_b1", "<[:w--[_]]+>", 2, "a", 0 },
+    { "a_b1", "<[:w&&[^_]]+>", 2, "a", 0 },
+    { "a_b1", "<[:w&&[:d]]>", 1, "1", 3 },
+    { "abcdef", "<[a-f&&[c-z]--[e]]+>", 2, "cd", 2 },
+    { "abcdef", "<[a-f--[b--[a-z--[c]]]]+>", 1, "abcdef", 0 },
+    { "abc", "<[^a-z--[b]]>", 1, "b", 1 },
+    { "ñandú", "#&<[:a--[:p{Latin}--[a-z]]]+>", 1, "and", 2 },
+    { "ÑAndú", "<[a-zñ--[a]]+#*>", 2, "Ñ", 0 },
+    { "a&&b", "<[&&]+>", 1, "&&", 1 },
+    { "a-[b", "<[:-:[]+>", 1, "-[", 1 },
+    { "x:]y", "<[:::]&&[:]]]+>", 1, "]", 2 },
+  }
+
+  for _, c := range setTest {
+    var r RE
+    x := r.Match( c.txt, c.re )
+    if x != c.n || r.GetCatch( 1 ) != c.catch || r.GpsCatch( 1 ) != c.pos {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q\nGpsCatch( 1 ) == %d, expected %d",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch, r.GpsCatch( 1 ), c.pos )
+    }
+
+    if _, err := CompileErr( c.re ); err != nil { t.Errorf( "CompileErr( %q ) == %v, expected no error", c.re, err ) }
+  }
+}
+
+func jTest( t *testing.T ){
+  escapeTest := []struct {
+    txt, re string
+    n int
+    catch string
+    pos int
+  }{
+    { "a\tb\nc\r", "<:t>", 1, "\t", 1 },
+    { "a\tb\nc\r", "<:n>", 1, "\n", 3 },
+    { "a\tb\nc\r", "<:r>", 1, "\r", 5 },
+    { "a\tb\nc\r", "<[:t:n:r]>", 3, "\t", 1 },
+    { "a\tb\nc\r", "<[^:t:n:r]+>", 3, "a", 0 },
+    { "ABC", "<:x41:x42+>", 1, "AB", 0 },
+    { "x\x00y", "<:x00>", 1, "\x00", 1 },
+    { "a\xffb", "<:xff>", 1, "\xff", 1 },
+    { "😀x😀", "<:u{1F600}>", 2, "😀", 0 },
+    { "ñandú", "<:u{f1}>", 1, "ñ", 0 },
+    { "ÑANDÚ", "#*<:u{f1}and:u{fa}>", 1, "ÑANDÚ", 0 },
+    { "мир", "<[:u{430}-:u{44F}]+>", 1, "мир", 0 },
+    { "ABz", "<[:x41-Z]+>", 1, "AB", 0 },
+    { "xyz", "<:x>", 1, "x", 0 },
+    { "u{1}", "<:u>", 1, "u", 0 },
+    { "ña", "(?<=:u{f1})<a>", 1, "a", 2 },
+  }
+
+  for _, c := range escapeTest {
+    var r RE
+    x := r.Match( c.txt, c.re )
+    if x != c.n || r.GetCatch( 1 ) != c.catch || r.GpsCatch( 1 ) != c.pos {
+      t.Errorf( "Regexp4( %q, %q ) == %d, expected %d\nGetCatch( 1 ) == %q, expected %q\nGpsCatch( 1 ) == %d, expected %d",
+                c.txt, c.re, x, c.n, r.GetCatch( 1 ), c.catch, r.GpsCatch( 1 ), c.pos )
+    }
+  }
+}
+
+func qTest( t *testing.T ){
+  bomb, exp := strings.Repeat( "a", 40 ), "<(a|aa)*>@1[c]"
+
+  var re RE
+  re.Compile( exp ).SetStepLimit( 10000 )
+  if n := re.MatchString( bomb ); n != 0 || re.Err() != ErrStepLimit {
+    t.Errorf( "SetStepLimit( 10000 ).MatchString() == %d, %v, expected 0, %v", n, re.Err(), ErrStepLimit )
+  }
+
+  if n := re.MatchString( "aac aac" ); n != 2 || re.Err() != nil {
+    t.Errorf( "SetStepLimit( 10000 ).MatchString( \"aac aac\" ) == %d, %v, expected 2, <nil>", n, re.Err() )
+  }
+
+  re.Compile( "a" ).SetStepLimit( 5 )
+  if n := re.MatchString( "aaaaaaaaaa" ); n == 0 || n == 10 || re.Err() != ErrStepLimit {
+    t.Errorf( "SetStepLimit( 5 ).MatchString() == %d, %v, expected some matches and %v", n, re.Err(), ErrStepLimit )
+  }
+
+  re.SetStepLimit( 0 )
+  if n := re.MatchString( "aaaaaaaaaa" ); n != 10 || re.Err() != nil {
+    t.Errorf( "SetStepLimit( 0 ).MatchString() == %d, %v, expected 10, <nil>", n, re.Err() )
+  }
+
+  for _, exp := range []string{ "(a*)*b", "(a|a?)+b" } {
+    if n := re.Compile( exp ).MatchString( "cb" + strings.Repeat( "a", 26 ) ); n != 1 || re.Err() != ErrStepLimit {
+      t.Errorf( "MatchString( %q ) == %d, %v, expected 1, %v by the budget of the backtracking", exp, n, re.Err(), ErrStepLimit )
+    }
+  }
+
+  if n := re.Compile( "(a?)*b" ).MatchString( strings.Repeat( "aaab", 100 ) ); n != 100 || re.Err() != nil {
+    t.Errorf( "MatchString( \"(a?)*b\" ) == %d, %v, expected 100, <nil>", n, re.Err() )
+  }
+
+  deep := strings.Repeat( "ab", maxDepth ) + "c"
+  if n := re.Compile( "(ab)*(?=c)" ).MatchString( deep ); n != 0 || re.Err() != ErrDepthLimit {
+    t.Errorf( "MatchString( deep ) == %d, %v, expected 0, %v", n, re.Err(), ErrDepthLimit )
+  }
+
+  if n := re.MatchString( deep[maxDepth:] ); n != 2 || re.Err() != nil {
+    t.Errorf( "MatchString( deep[maxDepth:] ) == %d, %v, expected 2, <nil>", n, re.Err() )
+  }
+
+  if n := re.MatchReader( strings.NewReader( deep ) ); n != 0 || re.Err() != ErrDepthLimit {
+    t.Errorf( "MatchReader( deep ) == %d, %v, expected 0, %v", n, re.Err(), ErrDepthLimit )
+  }
+
+  track := "(" + strings.Repeat( "a?", 200 ) + "b)*(?=c)"
+  if n := re.Compile( track ).MatchString( strings.Repeat( "b", 60000 ) + "c" ); n != 0 || re.Err() != ErrDepthLimit {
+    t.Errorf( "MatchString( %q ) == %d, %v, expected 0, %v", track, n, re.Err(), ErrDepthLimit )
+  }
+
+  ctx, cancel := context.WithCancel( context.Background() )
+  cancel()
+  if n, err := Compile( "a" ).MatchStringContext( ctx, "aaa" ); n != 0 || err != context.Canceled {
+    t.Errorf( "MatchStringContext( canceled ) == %d, %v, expected 0, %v", n, err, context.Canceled )
+  }
+
+  ctx, cancel = context.WithTimeout( context.Background(), 20 * time.Millisecond )
+  defer cancel()
+  if n, err := Compile( exp ).Regexp.MatchStringContext( ctx, bomb ); n != 0 || err != context.DeadlineExceeded {
+    t.Errorf( "MatchStringContext( timeout ) == %d, %v, expected 0, %v", n, err, context.DeadlineExceeded )
+  }
+
+  if n, err := Compile( "<a+>" ).MatchStringContext( context.Background(), "aa aa" ); n != 2 || err != nil {
+    t.Errorf( "MatchStringContext() == %d, %v, expected 2, <nil>", n, err )
+  }
+
+  limited := Compile( exp ).Regexp.WithStepLimit( 10000 )
+  if n, err := limited.MatchStringContext( context.Background(), bomb ); n != 0 || err != ErrStepLimit {
+    t.Errorf( "WithStepLimit( 10000 ).MatchStringContext() == %d, %v, expected 0, %v", n, err, ErrStepLimit )
+  }
+
+  if m := limited.NewMatcher(); m.MatchString( bomb ) != 0 || m.Err() != ErrStepLimit {
+    t.Errorf( "WithStepLimit( 10000 ).NewMatcher().Err() == %v, expected %v", m.Err(), ErrStepLimit )
+  }
+
+  if n := limited.MatchString( "aac" ); n != 1 {
+    t.Errorf( "WithStepLimit( 10000 ).MatchString( \"aac\" ) == %d, expected 1", n )
+  }
+}
+
+func vTest( t *testing.T ){
+  prefixTest := []struct {
+    re, prefix, first string
+  }{
+    { "Raptor", "Raptor", "R" },
+    { "<Raptor> Test", "Raptor", "R" },
+    { "(?<=:s)Raptor", "Raptor", "R" },
+    { ":mRaptor", "Raptor", "R" },
+    { "#*Raptor", "", "Rr" },
+    { "#*k", "", "Kk\xe2" },
+    { "[0-9]+", "", "0123456789" },
+    { "a|b[cd]|e?f", "", "abef" },
+    { "(ab)?c", "", "ac" },
+    { "a*", "", "" },
+    { "<.*>:.txt", "", "" },
+    { "[^a]", "", "" },
+    { ":x80", "", "" },
+    { "[а-я]", "", "\xd0\xd1" },
+  }
+
+  for _, c := range prefixTest {
+    re, first := Compile( c.re ), ""
+    if re.first != nil {
+      for b := range re.first {
+        if re.first[b] { first += string( []byte{ byte( b ) } ) }
+      }
+    }
+
+    if re.prefix != c.prefix || first != c.first {
+      t.Errorf( "Compile( %q ) prefix, first == %q, %q, expected %q, %q", c.re, re.prefix, first, c.prefix, c.first )
+    }
+  }
+
+  txts := []string{
+    "Raptor Test Raptor", "raptor RAPTOR", "0a12 b345", "abcdef ace bdf", "a\xffb\x80c",
+    "file.txt a.b.txt", "мир world", "K k \u212a", "line\nRaptor\nraptor", "",
+    "joe@example.com", "joe@example.org", "aaab abbbc aaa", "Test Raptor ",
+  }
+
+  res := []string{
+    "Raptor", "<Raptor>", "(?<=:s)Raptor", ":mRaptor", "#*Raptor", "#*k", "[0-9]+", "<[0-9]+>",
+    "a|b[cd]|e?f", "(ab)?c", "<(ab)?c>", "a*", "<.*>:.txt", "[^a]", ":x80", "[а-я]+", "#^!:hRaptor",
+    "#!:hR:w+", "#~a", "#/b", "#?c", "<:w+>@1", "#&:p{L}+", "#$t",
+    "<[a-z]+>:@example:.com", "(Raptor|Test)+ ", "a{3}", "ab+c", "R(?=a)aptor", "(?!R)aptor", "(x|:w)b",
+  }
+
+  for _, txt := range txts {
+    for _, pattern := range res {
+      var fast, slow RE
+      fast.Compile( pattern )
+      slow.Compile( pattern )
+      plain := *slow.Regexp
+      plain.prefix, plain.first, plain.required = "", nil, nil
+      slow.Regexp = &plain
+
+      f, s := fast.MatchString( txt ), slow.MatchString( txt )
+      fast.capture(); slow.capture()
+      if f != s || !reflect.DeepEqual( fast.matches, slow.matches ) ||
+        !reflect.DeepEqual( fast.catches[:fast.catchIndex], slow.catches[:slow.catchIndex] ) {
+        t.Errorf( "Regexp4( %q, %q ) == %d %v, expected %d %v", txt, pattern, f, fast.matches, s, slow.matches )
+      }
+    }
+  }
+}
+
+func tTest( t *testing.T ){
+  requiredTest := []struct {
+    re string
+    required [][]string
+  }{
+    { "Raptor", [][]string{ { "Raptor" } } },
+    { "<[a-z]+>:@example:.com", [][]string{ { "@example.com" } } },
+    { "<Raptor> Test", [][]string{ { "Raptor" }, { " Test" } } },
+    { "(foo|bar)baz", [][]string{ { "foo", "bar" }, { "baz" } } },
+    { "(foo|:w+)baz", [][]string{ { "baz" } } },
+    { "a{3}b", [][]string{ { "aaab" } } },
+    { "ab+c", [][]string{ { "ab" }, { "c" } } },
+    { "(ab)?c:d", [][]string{ { "c" } } },
+    { "R:mapt(?=o)or", [][]string{ { "Raptor" } } },
+    { "x(?=yy)z", [][]string{ { "xz" } } },
+    { "#*Raptor", nil },
+    { "<:w+>@1", nil },
+    { "a:sb:sc:sd", [][]string{ { "a" }, { "b" }, { "c" } } },
+  }
+
+  for _, c := range requiredTest {
+    if re := Compile( c.re ); !reflect.DeepEqual( re.required, c.required ) {
+      t.Errorf( "Compile( %q ).required == %q, expected %q", c.re, re.required, c.required )
+    }
+  }
+
+  logs := Compile( "<[a-z]+>:@example:.com" ).Regexp
+  if n := logs.MatchString( "GET /index.html 200" ); n != 0 {
+    t.Errorf( "MatchString() == %d, expected 0", n )
+  }
+
+  if n := logs.MatchString( "from joe@example.com" ); n != 1 {
+    t.Errorf( "MatchString() == %d, expected 1", n )
+  }
+}
+
+func vmTest( t *testing.T ){
+  loweredTest := []struct {
+    re string
+    pike bool
+  }{
+    { "Raptor", true },
+    { "<(a|aa)*>[c]", true },
+    { "<:w+>@1", false },
+    { "R(?=a)aptor", false },
+    { "(?<=:s)Raptor", false },
+    { "a++b", false },
+    { "(a?)*b", false },
+    { "(a?)?b", true },
+    { "#^$<[0-9]{2,4}?>-:d", true },
+  }
+
+  for _, c := range loweredTest {
+    if re := Compile( c.re ); (re.pike != nil) != c.pike {
+      t.Errorf( "Compile( %q ).pike != nil == %v, expected %v", c.re, re.pike != nil, c.pike )
+    }
+  }
+
+  bomb := strings.Repeat( "a", 5000 )
+  re := Compile( "(a|aa)*[c]" ).Regexp.WithStepLimit( 1000000 )
+  if n, err := re.MatchStringContext( context.Background(), bomb ); n != 0 || err != nil {
+    t.Errorf( "MatchStringContext( bomb ) == %d, %v, expected 0, <nil>", n, err )
+  }
+
+  var events RE
+  if events.Compile( "<a>[bc]" ).MatchString( strings.Repeat( "a", 1 << 16 ) + "b" ) != 1 || events.TotCatch() != 1 || cap( events.events ) > 4 * pikeEvents {
+    t.Errorf( "MatchString( \"<a>[bc]\" ) kept %d events, expected %d at most", cap( events.events ), 4 * pikeEvents )
+  }
+
+  alt := strings.Repeat( "ab", 2000 ) + "c"
+  if events.Compile( "(<a>|<b>)*c" ).MatchString( alt ) != 1 || events.TotCatch() != 4000 ||
+    events.GetCatch( 3999 ) != "a" || events.GetCatch( 4000 ) != "b" || events.GpsCatch( 4000 ) != 3999 {
+    t.Errorf( "MatchString( \"(<a>|<b>)*c\" ) == %d catches, expected 4000", events.TotCatch() )
+  }
+
+  loop := strings.Repeat( "a", 3000 ) + "b"
+  if events.Compile( "(<a>)*b" ).MatchString( loop ) != 1 || events.TotCatch() != 3000 || events.GetCatch( 3000 ) != "a" {
+    t.Errorf( "MatchString( \"(<a>)*b\" ) == %d catches, expected 3000", events.TotCatch() )
+  }
+
+  txts := []string{
+    "Raptor Test Raptor", "raptor RAPTOR", "0a12 b345", "abcdef ace bdf", "a\xffb\x80c",
+    "aaab abbbc aaa", "мир world ñandú", "line\nRaptor\nraptor\n", "", "aaaa",
+    "<a><b>c</b></a>", "2024-10-18 12:30", "ab ab abab", "xyxyxyz",
+  }
+
+  res := []string{
+    "a*", "a*?", "a+?b", "(a|ab)(c|bcd)?", "<(a|aa)*>", "<<a>|<b>>+", "<a{2,3}>", "<a{1,2}?>",
+    "<(ab)+>", "<(ab)*?>c", "(x|y)*z", "<[a-z]+>", "<.*?>", "<<.>*>", "#^<:w+>", "#$<:w+>",
+    "#!^<R:w+>", "#!$:w+", "#~<a+>", "#/<b+>", "#?c", "#*<raptor>", "#&<:p{L}+>", ":b<:w+>:b",
+    "<:d{4}>-<:d+>-<:d+>", "[^ ]+ ", "<(a)?b>", "<<a>|b>*c", "(<a>|<b>)+", "<a|>b", ":B<a>",
+  }
+
+  for _, txt := range txts {
+    for _, pattern := range res {
+      var fast, slow RE
+      fast.Compile( pattern )
+      slow.Compile( pattern )
+      plain := *slow.Regexp
+      plain.pike, plain.dfa = nil, false
+      slow.Regexp = &plain
+
+      f, s := fast.MatchString( txt ), slow.MatchString( txt )
+      fast.capture(); slow.capture()
+      if f != s || !reflect.DeepEqual( fast.matches, slow.matches ) ||
+        !reflect.DeepEqual( fast.catches[:fast.catchIndex], slow.catches[:slow.catchIndex] ) {
+        t.Errorf( "Regexp4( %q, %q ) == %d %v %v, expected %d %v %v", txt, pattern, f, fast.matches,
+                  fast.catches[:fast.catchIndex], s, slow.matches, slow.catches[:slow.catchIndex] )
+      }
+    }
+  }
+}
+
+func dfaTest( t *testing.T ){
+  loweredTest := []struct {
+    re string
+    dfa bool
+  }{
+    { "Raptor", true },
+    { "<(a|aa)*>[c]", true },
+    { "#*<raptor>", true },
+    { "#^$<[0-9]{2,4}?>-:d", true },
+    { "<:w+>@1", false },
+    { ":b<:w+>", true },
+    { "R:mapt:hor", false },
+    { "#~<a+>", false },
+    { "a:xff", false },
+    { "#*a:xff", true },
+  }
+
+  for _, c := range loweredTest {
+    if re := Compile( c.re ); re.dfa != c.dfa {
+      t.Errorf( "Compile( %q ).dfa == %v, expected %v", c.re, re.dfa, c.dfa )
+    }
+  }
+
+  var re RE
+  pad := strings.Repeat( " ", dfaText )
+  if n := re.Match( "Raptor Test, raptor test" + pad, "#*<R:w+> <t:w+>" ); n != 2 || !re.pending {
+    t.Errorf( "Regexp4() == %d, pending %v, expected 2, true", n, re.pending )
+  }
+
+  if re.GetCatch( 1 ) != "Raptor" || re.GetCatch( 4 ) != "test" || re.TotCatch() != 4 || re.pending {
+    t.Errorf( "GetCatch( 1 ), GetCatch( 4 ), TotCatch() == %q, %q, %d, expected \"Raptor\", \"test\", 4",
+              re.GetCatch( 1 ), re.GetCatch( 4 ), re.TotCatch() )
+  }
+
+  b := []byte( "ñandú ñu" + pad )
+  re.Compile( "<ñ[^ ]+>" ).MatchBytes( b )
+  if c := re.GetCatchBytes( 2 ); string( c ) != "ñu" || &c[0] != &b[8] {
+    t.Errorf( "GetCatchBytes( 2 ) == %q, expected a subslice \"ñu\"", c )
+  }
+
+  if !Compile( "b+" ).FindString( "aaab" + pad ) || Compile( "c" ).FindString( "aaab" + pad ) {
+    t.Errorf( "FindString() with the lazy DFA" )
+  }
+
+  re.Compile( "a" ).SetStepLimit( 700 )
+  if n := re.MatchString( strings.Repeat( "a", 1000 ) ); n == 0 || re.Err() != ErrStepLimit || re.MatchCount() != n {
+    t.Errorf( "MatchString() with step limit == %d, %v, MatchCount() == %d, expected the matches before the limit",
+              n, re.Err(), re.MatchCount() )
+  }
+
+  if m := Compile( "raptor" ).NewMatcher(); m.FindString( "raptor" ) && m.states != nil {
+    t.Errorf( "FindString() of a short text made the DFA cache" )
+  }
+
+  bits := make( []byte, 1 << 16 )
+  for i, seed := 0, uint32( 1 ); i < len( bits ); i++ {
+    seed    = seed * 1103515245 + 12345
+    bits[i] = "ab"[ seed >> 16 & 1 ]
+  }
+
+  thrash := Compile( "[ab]*a[ab]{12}c" )
+  plain  := *thrash.Regexp
+  plain.dfa = false
+  txt := string( bits ) + "abbbbbbbbbbbbc"
+  if n, m := thrash.MatchString( txt ), plain.MatchString( txt ); n != m || n != 1 || thrash.pending {
+    t.Errorf( "MatchString( thrash ) == %d, pending %v, expected %d, false", n, thrash.pending, m )
+  }
+}
+
+func memoTest( t *testing.T ){
+  memoErrTest := []struct {
+    re string
+    err error
+  }{
+    { "(a*)*b", nil },
+    { "(a+)+b", nil },
+    { "(x(a*)*)*b", nil },
+    { "(a|aa)*(?=b)", nil },
+    { "<a|b>c", nil },
+    { "a++b", nil },
+    { "<(a|aa)*>@1[c]", ErrNoMemo },
+    { "(a|aa){2,30}c", ErrNoMemo },
+    { "(?<=(a|b)c)d", ErrNoMemo },
+    { "(a*b*)*c", ErrNoMemo },
+  }
+
+  for _, c := range memoErrTest {
+    if _, err := Compile( c.re ).Regexp.WithMemo(); err != c.err {
+      t.Errorf( "Compile( %q ).WithMemo() == %v, expected %v", c.re, err, c.err )
+    }
+  }
+
+  bomb := strings.Repeat( "a", 40 )
+  for _, exp := range []string{ "(a*)*[b]", "(x|a|a*)*[c]", "<(a|aa)*>(?=b)" } {
+    memo, _ := Compile( exp ).Regexp.WithMemo()
+    if n, err := memo.WithStepLimit( 100000 ).MatchStringContext( context.Background(), bomb ); n != 0 || err != nil {
+      t.Errorf( "WithMemo().MatchStringContext( %q ) == %d, %v, expected 0, <nil>", exp, n, err )
+    }
+  }
+
+  txts := []string{
+    "aaab abbbc aaa", "ab ab abab", "xyxyxyz", "Raptor Test Raptor", "line\nRaptor\nraptor\n", "",
+    "aaaaaaaaab", "abba", "abcabc abab", "ñandú ñu", "a1b2c3 a12", "xcab", " cccñb11a1a",
+  }
+
+  res := []string{
+    "(a*)*b", "(a+)+b", "<(a*)*>b", "<(a|ab)*?>c", "<(a*)>*b", "((a*)*)*b", "<a*>*@1b", "<(a|b)>@1",
+    "#^<:w+>", "#!$:w+", "#~<a+>", "#/<b+>", "#*<raptor>", "(?=ab)<a|ab>", "(?<=a)<b+>", "(?<!b)a",
+    "<(ab)++>", "<(a|b){2,3}>", "<a{1,3}?>b", "R:mapt:hor", "(<a>|<b>)+", "<<a>|b>*c", "<([a-z]*)*>[0-9]",
+    "#~([^a]??|a?)+?b", "#~([^a]{0,2}?|(b++<a|b>{0,2}?:d{0,2}?)*)+(b*+(a|ab)+?[a-c]*)", "<(a?|b)*>c",
+  }
+
+  for _, pattern := range res {
+    memo, _ := Compile( pattern ).Regexp.WithMemo()
+    plain := *Compile( pattern ).Regexp
+    plain.pike, plain.dfa = nil, false
+
+    for _, txt := range txts {
+      fast, slow := memo.NewMatcher(), plain.NewMatcher()
+      if f, s := fast.MatchString( txt ), slow.MatchString( txt ); f != s || !reflect.DeepEqual( fast.matches, slow.matches ) ||
+        !reflect.DeepEqual( fast.catches[:fast.catchIndex], slow.catches[:slow.catchIndex] ) {
+        t.Errorf( "Regexp4( %q, %q ) == %d %v, expected %d %v", txt, pattern, f, fast.matches, s, slow.matches )
+      }
+    }
+  }
+}
+
 ////////////// INTERNAL-COMPARATIVE-BENCHMARKS
 /// Find vs [Compile() + Copy().FindStirng()]
 
@@ -2131,6 +3480,7 @@ func BenchmarkFindCopySimple(b *testing.B) {
 /// RplCatch (string vs []byte vs bytes.Buffer)
 
 func (r *RE) OldRplCatch( rplStr string, id int ) (result string) {
+  r.capture()
   last := 0
 
   for index := 1; index < r.catchIndex; index++ {
@@ -2149,6 +3499,7 @@ func (r *RE) OldRplCatch( rplStr string, id int ) (result string) {
 }
 
 func (r *RE) BufferRplCatch( rplStr string, id int ) string {
+  r.capture()
   last := 0
   var b bytes.Buffer
 
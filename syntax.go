@@ -0,0 +1,432 @@
+package regexp4
+
+// SyntaxError describes a malformed expression, as reported by CompileErr
+type SyntaxError struct {
+  Offset int    // byte offset of the construct inside the expression
+  Expr   string // offending construct
+  Msg    string // human description of the problem
+}
+
+func (e *SyntaxError) Error() string {
+  return "regexp4: " + e.Msg + " at offset " + iToa( e.Offset ) + ": `" + e.Expr + "`"
+}
+
+type syntax struct {
+  re    string
+  pos   int
+  hooks int
+  names map[string]int // hook id of each name
+}
+
+// checkSyntax walks the expression with the same rules applied by tracker,
+// cutByType and getLoops, and reports the first construct they can not cut
+func checkSyntax( re string ) error {
+  s := syntax{ re: re, names: map[string]int{} }
+
+  if len( re ) > 0 && re[0] == '#' {
+    if err := s.mods(); err != nil { return err }
+  }
+
+  if _, err := s.path(); err != nil { return err }
+
+  if s.pos < len( s.re ) {
+    return s.fail( s.pos, s.pos + 1, "unexpected " + quoteChar( s.re[s.pos] ) )
+  }
+
+  return nil
+}
+
+func (s *syntax) fail( init, end int, msg string ) error {
+  if end > len( s.re ) { end = len( s.re ) }
+  return &SyntaxError{ Offset: init, Expr: s.re[init:end], Msg: msg }
+}
+
+// width is the range of bytes that a construct can match
+type width struct { min, max int }
+
+func (w width) add( t width, loopsMin, loopsMax int ) width {
+  w.min += t.min * loopsMin
+  w.max += t.max * loopsMax
+  if w.min > inf { w.min = inf }
+  if w.max > inf { w.max = inf }
+  return w
+}
+
+func (s *syntax) path() (width, error) {
+  w := width{ inf, 0 }
+  for bar, empty, hooks, maxHooks := -1, -1, s.hooks, s.hooks; ; {
+    track  := s.pos
+    s.hooks = hooks
+    t, err := s.tracks()
+    if err != nil { return w, err }
+    if s.hooks > maxHooks { maxHooks = s.hooks }
+    if t.min < w.min { w.min = t.min }
+    if t.max > w.max { w.max = t.max }
+
+    if s.pos < len( s.re ) && s.re[s.pos] == '|' {
+      if s.pos == track && empty == -1 { empty = s.pos }
+      bar    = s.pos
+      s.pos++
+      continue
+    }
+
+    s.hooks = maxHooks
+    if s.pos == track && empty == -1 { empty = bar }
+    if empty != -1 { return w, s.fail( empty, empty + 1, "missing alternative" ) }
+    return w, nil
+  }
+}
+
+func (s *syntax) tracks() (w width, err error) {
+  for s.pos < len( s.re ) {
+    switch s.re[s.pos] {
+    case '|', ')', '>': return
+    }
+
+    var t width
+    var loopsMin, loopsMax int
+    if t, loopsMin, loopsMax, err = s.track(); err != nil { return }
+    w = w.add( t, loopsMin, loopsMax )
+  }
+
+  return
+}
+
+func (s *syntax) track() (w width, loopsMin, loopsMax int, err error) {
+  init, zero := s.pos, ""
+
+  if s.re[s.pos] > 127 {
+    s.pos += utf8meter( s.re[s.pos:] )
+    w      = width{ s.pos - init, s.pos - init }
+  } else {
+    w = width{ 1, 1 }
+    switch s.re[s.pos] {
+    case ':':
+      if s.pos + 1 >= len( s.re ) { return w, 0, 0, s.fail( init, s.pos + 1, "missing escaped character" ) }
+      if isAnchor( s.re[s.pos:] ) { w, zero = width{}, "anchor" }
+      if s.re[s.pos + 1] == 'p' || s.re[s.pos + 1] == 'P' {
+        n, err := s.classAt( s.pos )
+        if err != nil { return w, 0, 0, err }
+        if n > 0 { w.max, s.pos = utf8Max, s.pos + n; break }
+      }
+
+      if n, err := s.escapeAt( s.pos ); err != nil { return w, 0, 0, err
+      } else if n > 0 {
+        w      = width{ len( unescape( s.re[s.pos:s.pos + n] ) ), len( unescape( s.re[s.pos:s.pos + n] ) ) }
+        s.pos += n
+        break
+      }
+
+      if strnchr( "ADWSB&", rune( s.re[s.pos + 1] ) ) { w.max = utf8Max }
+      s.pos += 2
+    case '.':
+      s.pos++
+      w.max = utf8Max
+    case '@':
+      s.pos++
+      if s.pos < len( s.re ) && s.re[s.pos] == '{' {
+        name, err := s.name()
+        if err != nil { return w, 0, 0, err }
+
+        if id, ok := s.names[name]; !ok || id > s.hooks {
+          return w, 0, 0, s.fail( init, s.pos, "backreference to undefined catch" )
+        }
+
+        w = width{ 0, inf }
+        break
+      }
+
+      digits := countCharDigits( s.re[s.pos:] )
+      if digits == 0 { return w, 0, 0, s.fail( init, s.pos, "missing backreference id" ) }
+
+      s.pos += digits
+      if id := aToi( s.re[init+1:s.pos] ); digits > 9 || id < 1 || id > s.hooks {
+        return w, 0, 0, s.fail( init, s.pos, "backreference to undefined catch" )
+      }
+
+      w = width{ 0, inf }
+    case '(', '<':
+      if lookAround( s.re[s.pos:] ) > 0 { zero = "lookaround assertion" }
+      if w, err = s.group(); err != nil { return }
+    case '[':
+      if err = s.set(); err != nil { return }
+      w.max = utf8Max
+    case '?', '+', '*', '{':
+      return w, 0, 0, s.fail( init, init + 1, "missing argument to repetition operator" )
+    case '#':
+      return w, 0, 0, s.fail( init, init + 1, "missing argument to modifier" )
+    default : s.pos++
+    }
+  }
+
+  loops := s.pos
+  if loopsMin, loopsMax, err = s.loops(); err != nil { return }
+  if zero != "" && s.pos > loops {
+    return w, 0, 0, s.fail( loops, s.pos, "repetition of " + zero )
+  }
+
+  if s.pos < len( s.re ) && s.re[s.pos] == '#' {
+    err = s.mods()
+  }
+
+  return
+}
+
+func (s *syntax) group() (width, error) {
+  init, open := s.pos, s.re[s.pos]
+  close := byte( ')' )
+  look := lookAround( s.re[s.pos:] )
+  s.pos += 1 + look
+
+  if open == '<' {
+    close = '>'
+    s.hooks++
+
+    if s.pos < len( s.re ) && s.re[s.pos] == '{' {
+      nameInit  := s.pos
+      name, err := s.name()
+      if err != nil { return width{}, err }
+
+      if id, ok := s.names[name]; ok && id != s.hooks {
+        return width{}, s.fail( nameInit, s.pos, "duplicate catch name" )
+      }
+
+      for n, id := range s.names {
+        if id == s.hooks && n != name {
+          return width{}, s.fail( nameInit, s.pos, "catch already named {" + n + "}" )
+        }
+      }
+
+      s.names[name] = s.hooks
+    }
+  }
+  w, err := s.path()
+  if err != nil { return w, err }
+
+  if s.pos >= len( s.re ) {
+    return w, s.fail( init, init + 1 + look, "missing closing " + quoteChar( close ) )
+  }
+
+  if s.re[s.pos] != close {
+    return w, s.fail( s.pos, s.pos + 1, "unexpected " + quoteChar( s.re[s.pos] ) )
+  }
+
+  s.pos++
+  if look == 3 && w.max >= inf {
+    return w, s.fail( init, s.pos, "lookbehind of unbounded length" )
+  }
+
+  if look > 0 { return width{}, nil }
+  return w, nil
+}
+
+// classAt checks the Unicode class ":p{name}" at init, returns its length or 0
+// when it is the escape of 'p'
+func (s *syntax) classAt( init int ) (int, error) {
+  if init + 2 >= len( s.re ) || s.re[init + 2] != '{' || (s.re[init + 1] != 'p' && s.re[init + 1] != 'P') {
+    return 0, nil
+  }
+
+  n := classLen( s.re[init:] )
+  if n == 0 { return 0, s.fail( init, len( s.re ), "missing closing '}'" ) }
+
+  if _, ok := unicodeClasses[ s.re[init + 3:init + n - 1] ]; !ok {
+    return 0, s.fail( init, init + n, "unknown Unicode class" )
+  }
+
+  return n, nil
+}
+
+// escapeAt checks the escape ":xHH" or ":u{H...}" at init, returns its length,
+// or 0 when it is other meta
+func (s *syntax) escapeAt( init int ) (int, error) {
+  n := escapeLen( s.re[init:] )
+  if n == 0 && init + 1 < len( s.re ) && (s.re[init + 1] == 'x' || s.re[init + 1] == 'u') {
+    return 0, s.fail( init, init + 2, "invalid escape" )
+  }
+
+  if n > 0 && s.re[init + 1] == 'u' {
+    if c := hexToi( s.re[init + 3:init + n - 1] ); c >= 0xD800 && c <= 0xDFFF {
+      return 0, s.fail( init, init + n, "invalid code point" )
+    }
+  }
+
+  return n, nil
+}
+
+// setEscapeAt is escapeAt inside a set, the escapes that setEscapeLen does not
+// take are errors
+func (s *syntax) setEscapeAt( init int ) (int, error) {
+  n, err := s.escapeAt( init )
+  if n > 0 && setEscapeLen( s.re[init:] ) == 0 {
+    return 0, s.fail( init, init + n, "byte escape in set" )
+  }
+
+  return n, err
+}
+
+// name reads the "{name}" of a hook or a backreference, a name is made of
+// letters, digits and '_'
+func (s *syntax) name() (string, error) {
+  init := s.pos
+  for s.pos++; s.pos < len( s.re ) && s.re[s.pos] != '}'; s.pos++ {
+    if c := rune( s.re[s.pos] ); !isAlnum( c ) && c != '_' {
+      return "", s.fail( init, s.pos + 1, "invalid catch name" )
+    }
+  }
+
+  if s.pos >= len( s.re ) { return "", s.fail( init, s.pos, "missing closing '}'" ) }
+
+  s.pos++
+  if s.pos == init + 2 { return "", s.fail( init, s.pos, "missing catch name" ) }
+
+  return s.re[init + 1:s.pos - 1], nil
+}
+
+func (s *syntax) set() error {
+  init := s.pos
+  end  := init + 1 + walkSet( s.re[init+1:] )
+  if end >= len( s.re ) { return s.fail( init, init + 1, "missing closing ']'" ) }
+
+  if err := s.setBody( init, end ); err != nil { return err }
+
+  s.pos = end + 1
+  return nil
+}
+
+// setBody checks the set between the '[' at init and the ']' at end, its items
+// and the nested sets of its operators
+func (s *syntax) setBody( init, end int ) error {
+  set := init + 1
+  if s.re[set] == '^' { set++ }
+  if set == end { return s.fail( init, end + 1, "empty set" ) }
+
+  op := set + setOperator( s.re[set:end] )
+  if op == set { return s.fail( op, op + 3, "missing set operand" ) }
+
+  for i := set; i < op; {
+    size := 1
+    switch {
+    case s.re[i] > 127: size = utf8meter( s.re[i:op] )
+    case s.re[i] == ':':
+      size = 2
+      if n, err := s.classAt( i ); err != nil { return err
+      } else if n > 0 { size = n }
+
+      if n, err := s.setEscapeAt( i ); err != nil { return err
+      } else if n > 0 { size = n }
+    }
+
+    if s.re[i] == '-' {
+      return s.fail( i, i + 1, "unescaped '-' in set" )
+    } else if i + size < op && s.re[i + size] == '-' {
+      lo, l := setRune( s.re[i:op] )
+      if l == 0 || i + size + 1 >= op {
+        return s.fail( i, i + size + 1, "invalid range" )
+      }
+
+      if _, err := s.setEscapeAt( i + size + 1 ); err != nil { return err }
+
+      hi, n := setRune( s.re[i + size + 1:op] )
+      if n == 0 {
+        return s.fail( i, i + size + 1, "invalid range" )
+      }
+
+      if lo > hi {
+        return s.fail( i, i + size + 1 + n, "invalid range" )
+      }
+
+      size += 1 + n
+    }
+
+    i += size
+  }
+
+  for op < end {
+    nested := op + 2
+    close  := nested + 1 + walkSet( s.re[nested + 1:end] )
+    if err := s.setBody( nested, close ); err != nil { return err }
+
+    if op = close + 1; op < end && !isSetOperator( s.re[op:end] ) {
+      return s.fail( op, op + 1, "missing set operator" )
+    }
+  }
+
+  return nil
+}
+
+func (s *syntax) loops() (min, max int, err error) {
+  min, max = 1, 1
+  if s.pos >= len( s.re ) { return }
+
+  init := s.pos
+  switch s.re[s.pos] {
+  case '?': s.pos++; min, max = 0,   1
+  case '+': s.pos++; min, max = 1, inf
+  case '*': s.pos++; min, max = 0, inf
+  case '{':
+    s.pos++
+    minDigits := countCharDigits( s.re[s.pos:] )
+    min, max   = aToi( s.re[s.pos:] ), 0
+    s.pos     += minDigits
+    if s.pos >= len( s.re ) { return min, max, s.fail( init, s.pos, "missing closing '}'" ) }
+
+    switch s.re[s.pos] {
+    case '}':
+      if minDigits == 0 { return min, max, s.fail( init, s.pos + 1, "missing repetition count" ) }
+      max = min
+    case ',':
+      s.pos++
+      maxDigits := countCharDigits( s.re[s.pos:] )
+      max        = aToi( s.re[s.pos:] )
+      s.pos     += maxDigits
+      if s.pos >= len( s.re ) || s.re[s.pos] != '}' {
+        return min, max, s.fail( init, s.pos, "missing closing '}'" )
+      }
+
+      if maxDigits == 0 {
+        if minDigits == 0 { return min, max, s.fail( init, s.pos + 1, "missing repetition count" ) }
+        max = inf
+      } else if maxDigits > 9 || max >= inf {
+        return min, max, s.fail( init, s.pos + 1, "repetition count too large" )
+      }
+    default:
+      return min, max, s.fail( init, s.pos + 1, "missing closing '}'" )
+    }
+
+    s.pos++
+    if minDigits > 9 || min >= inf { return min, max, s.fail( init, s.pos, "repetition count too large" ) }
+    if max < min                   { return min, max, s.fail( init, s.pos, "invalid repetition range" ) }
+  default: return
+  }
+
+  if s.pos < len( s.re ) && (s.re[s.pos] == '+' || s.re[s.pos] == '?') { s.pos++ }
+
+  if s.pos < len( s.re ) {
+    switch s.re[s.pos] {
+    case '?', '+', '*', '{':
+      return min, max, s.fail( init, s.pos + 1, "invalid nested repetition operator" )
+    }
+  }
+
+  return
+}
+
+func (s *syntax) mods() error {
+  init := s.pos
+  for s.pos++; s.pos < len( s.re ) && strnchr( "^$?~*/!&", rune( s.re[s.pos] ) ); s.pos++ {}
+
+  if s.pos == init + 1 {
+    if s.pos < len( s.re ) {
+      return s.fail( init, s.pos + 1, "unknown modifier " + quoteChar( s.re[s.pos] ) )
+    }
+
+    return s.fail( init, s.pos, "missing modifier" )
+  }
+
+  return nil
+}
+
+func quoteChar( c byte ) string {
+  return "'" + string( rune( c ) ) + "'"
+}